@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// listDuplicateMoviesHandler lists the pairs of movies which are likely duplicates, to be reviewed then merged
+func (app *application) listDuplicateMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.DuplicateFilters
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.YearTolerance = app.readInt(qs, "year_tolerance", 1, v)
+	input.RuntimeTolerance = app.readInt(qs, "runtime_tolerance", 10, v)
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	// The pairs are always ordered by normalized title
+	input.Sort = "id"
+	input.SortSafeList = []string{"id"}
+
+	data.ValidateDuplicateFilters(v, input.DuplicateFilters)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	duplicates, metadata, err := app.models.Movie.GetDuplicates(r.Context(), input.DuplicateFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "duplicates": duplicates}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeMovieHandler merges the movie into the one given in the body, the movie is soft deleted afterwards
+func (app *application) mergeMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Into int64 `json:"into"` // ID of the movie which is kept
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateMerge(v, id, input.Into); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movie.Merge(r.Context(), id, input.Into)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.logger.InfoContext(r.Context(), "movie merged", "movie_id", id, "into", movie.ID)
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"movie": movie}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
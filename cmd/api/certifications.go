@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) putMovieCertificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Certification string `json:"certification"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	certification := &data.Certification{
+		MovieID: id,
+		Country: httprouter.ParamsFromContext(r.Context()).ByName("country"),
+		Code:    input.Certification,
+	}
+
+	v := validator.New()
+	if data.ValidateCertification(v, certification); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Certification.Put(r.Context(), certification); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"certification": certification}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieCertificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	country := strings.ToUpper(httprouter.ParamsFromContext(r.Context()).ByName("country"))
+	if err := app.models.Certification.Delete(r.Context(), id, country); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "certification successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ratingLimit returns the maximum certification the user of the request may see: the preference of an
+// authenticated user, or the configured default for anonymous users. nil means no limit.
+func (app *application) ratingLimit(r *http.Request) *data.Certification {
+	user := app.contextGetUser(r)
+	if user.IsAnonymousUser() {
+		return app.config.ratings.anonymousMaxRating
+	}
+	return user.MaxRating
+}
+
+// attachCertifications loads the certifications of the movies
+func (app *application) attachCertifications(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	byMovie, err := app.models.Certification.GetAllForMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		movie.Certifications = byMovie[movie.ID]
+	}
+	return nil
+}
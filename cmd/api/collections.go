@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Sort = app.readString(qs, "sort", "id")
+	input.SortSafeList = []string{"id", "name", "-id", "-name"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	collections, metadata, err := app.models.Collection.GetAll(r.Context(), input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for i := range collections {
+		if collections[i].Artwork != nil {
+			app.fillImageURLs(collections[i].Artwork)
+		}
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "collections": collections}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection := &data.Collection{Name: input.Name, Description: input.Description}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Collection.Create(r.Context(), collection); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/collections/%d", collection.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"collection": collection}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCollectionHandler returns the collection with its movies in order
+func (app *application) showCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := app.models.Collection.GetMembers(r.Context(), collection.ID, app.ratingLimit(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	collection.Movies = members
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"collection": collection}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	// We use pointers here for the input in order to support partial update
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		collection.Name = *input.Name
+	}
+	if input.Description != nil {
+		collection.Description = *input.Description
+	}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Collection.Update(r.Context(), collection); err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"collection": collection}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	collection, err := app.models.Collection.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if collection.Artwork != nil {
+		app.deleteStoredImage(r, *collection.Artwork)
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "collection successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// putCollectionArtworkHandler accepts a multipart form with an "image" file (JPEG, PNG or WebP) which replaces
+// the artwork of the collection
+func (app *application) putCollectionArtworkHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.parseImageForm(w, r) {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	v := validator.New()
+	artwork, err := app.storeFormImage(r, v, fmt.Sprintf("collection-%d", collection.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	previous, err := app.models.Collection.SetArtwork(r.Context(), collection.ID, artwork)
+	if err != nil {
+		app.deleteStoredImage(r, *artwork)
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if previous != nil {
+		app.deleteStoredImage(r, *previous)
+	}
+	app.fillImageURLs(artwork)
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"artwork": artwork}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCollectionArtworkHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	previous, err := app.models.Collection.SetArtwork(r.Context(), id, nil)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if previous == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	app.deleteStoredImage(r, *previous)
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "artwork successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MovieID  int64 `json:"movie_id"`
+		Position int32 `json:"position"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	member := &data.CollectionMember{CollectionID: collection.ID, MovieID: input.MovieID, Position: input.Position}
+
+	v := validator.New()
+	if data.ValidateCollectionMember(v, member); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Collection.AddMovie(r.Context(), member); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("movie_id", "movie does not exist")
+			app.failValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrDuplicateCollectionMovie):
+			v.AddError("movie_id", "movie is already in the collection")
+			app.failValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"movie": member}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.readCollection(w, r)
+	if !ok {
+		return
+	}
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Position int32 `json:"position"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	member := &data.CollectionMember{CollectionID: collection.ID, MovieID: movieID, Position: input.Position}
+
+	v := validator.New()
+	if data.ValidateCollectionMember(v, member); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Collection.MoveMovie(r.Context(), member); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"movie": member}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeCollectionMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Collection.RemoveMovie(r.Context(), id, movieID); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "movie successfully removed from the collection"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readCollection reads the collection from the :id parameter, with the URLs of its artwork filled in.
+// If it does not exist, a response is already sent and false is returned.
+func (app *application) readCollection(w http.ResponseWriter, r *http.Request) (*data.Collection, bool) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	collection, err := app.models.Collection.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if collection.Artwork != nil {
+		app.fillImageURLs(collection.Artwork)
+	}
+	return collection, true
+}
+
+// attachCollections loads the collections the movies belong to
+func (app *application) attachCollections(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	byMovie, err := app.models.Collection.GetAllForMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		movie.Collections = byMovie[movie.ID]
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/settings"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// secretSettings accept a _FILE environment variable holding the path of a file with their value, e.g.
+// GREENLIGHT_SMTP_PASSWORD_FILE, and are redacted when the config is printed
+var secretSettings = []string{"db-dsn", "smtp-password"}
+
+// legacyEnv maps the environment variables used before GREENLIGHT_* to their setting, they are still read
+// with a warning
+var legacyEnv = map[string]string{"MAIL_SERVER_PASSWORD": "smtp-password"}
+
+// loadOptions are the flags driving the loading of the config, they are not settings themselves
+type loadOptions struct {
+	file        string // YAML or TOML file, chosen by its extension
+	printConfig bool
+}
+
+// configEntry is the effective value of a setting, as printed by -print-config
+type configEntry struct {
+	name   string
+	value  string // Not redacted, see [redact] before printing or logging it
+	source string // default, the config file, the environment variable or flag
+}
+
+// newFlagSet returns the flags of every setting bound to cfg, which holds the defaults once it returns.
+// The name of a flag is also the name of its setting in the config file and in the environment.
+func newFlagSet(cfg *config, opts *loadOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	fs.StringVar(&opts.file, "config", "", "YAML or TOML config file, see also "+settings.EnvPrefix+"CONFIG")
+	fs.BoolVar(&opts.printConfig, "print-config", false, "Print the effective config, with the secrets redacted, and exit")
+
+	fs.IntVar(&cfg.port, "port", 42069, "API server port")
+	fs.StringVar(&cfg.metricsAddr, "metrics-addr", "", "Address of the admin listener serving GET /metrics, e.g. localhost:9090 (disabled when empty)")
+	fs.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	fs.StringVar(&cfg.db.backend, "db-backend", backendPostgres, "Storage backend (postgres|memory), memory serves neither the people, credits, reviews, lists nor watch history")
+	fs.StringVar(&cfg.db.dsn, "db-dsn", settings.DefaultDSN, "PostgreSQL DSN")
+	fs.IntVar(&cfg.db.maxOpenConns, "db-max-open-conn", 25, "Max open connection pool for postgres database")
+	fs.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 15*time.Minute, "the duration after which an idle connection will be automatically closed by the health check")
+	fs.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", models.DefaultQueryTimeout, "Maximum duration of the database work of a single model call")
+	fs.BoolVar(&cfg.db.migrateOnStart, "migrate-on-start", false, "Apply the pending database migrations before serving (postgres backend only)")
+	fs.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum request per second")
+	fs.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
+	fs.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	fs.Var(stringsValue{&cfg.cors.trustedOrigins}, "cors-trusted-origins", "Space separated origins allowed to make cross-origin requests (default any origin)")
+	fs.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "Minimum level of the logs (debug|info|warn|error)")
+	fs.BoolVar(&cfg.features.signup, "feature-signup", true, "Allow new users to sign up")
+	fs.BoolVar(&cfg.features.reviews, "feature-reviews", true, "Allow users to write reviews")
+	fs.BoolVar(&cfg.features.recommendations, "feature-recommendations", true, "Serve the similar movies and the recommendations")
+	// The SMTP credentials are only required in staging and production, in development the emails fail to send
+	fs.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")
+	fs.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
+	fs.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	fs.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	fs.StringVar(&cfg.smtp.sender, "smtp-sender", "", "SMTP sender email address")
+	fs.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory where the uploaded images are stored")
+	fs.StringVar(&cfg.storage.baseURL, "storage-base-url", "/v1/images", "Base URL the uploaded images are served from")
+	fs.DurationVar(&cfg.recommendations.refreshInterval, "recommendations-refresh-interval", time.Hour, "Interval between two refreshes of the movie similarities (0 to disable)")
+	fs.StringVar(&cfg.tracing.exporter, "tracing-exporter", tracingExporterNone, "Where the spans are exported (none|stdout|otlp-file), the trace IDs are logged even with none")
+	fs.StringVar(&cfg.tracing.file, "tracing-file", "traces.jsonl", "File the otlp-file exporter appends the spans to")
+	fs.Var(certificationValue{&cfg.ratings.anonymousMaxRating}, "anonymous-max-rating", "Maximum certification shown to anonymous users, who can read the movies without a token, e.g. US:PG-13 (default no limit)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags]\n       %s migrate [flags] COMMAND\n\n", fs.Name(), fs.Name())
+		fmt.Fprintf(fs.Output(), "Every flag can also be set in the config file or with its %s environment variable,\n", settings.EnvPrefix)
+		fmt.Fprint(fs.Output(), "e.g. -db-max-open-conn is db-max-open-conn (or db: max-open-conn:) in the file and GREENLIGHT_DB_MAX_OPEN_CONN.\n")
+		fmt.Fprint(fs.Output(), "The flags take precedence over the environment, which takes precedence over the file.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// stringsValue is the flag of a list, the items are separated by spaces or commas
+type stringsValue struct {
+	values *[]string
+}
+
+func (s stringsValue) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, " ")
+}
+
+func (s stringsValue) Set(value string) error {
+	*s.values = strings.Fields(strings.ReplaceAll(value, ",", " "))
+	return nil
+}
+
+// certificationValue is the flag of an optional certification, empty for no certification
+type certificationValue struct {
+	certification **data.Certification
+}
+
+func (c certificationValue) String() string {
+	if c.certification == nil || *c.certification == nil {
+		return ""
+	}
+	return (*c.certification).Country + ":" + (*c.certification).Code
+}
+
+func (c certificationValue) Set(s string) error {
+	if s == "" {
+		*c.certification = nil
+		return nil
+	}
+	certification, err := data.ParseCertification(s)
+	if err != nil {
+		return err
+	}
+	*c.certification = certification
+	return nil
+}
+
+// loadConfig merges the defaults, the config file, the GREENLIGHT_* environment variables and the flags of
+// args, each one overriding the previous ones. Every problem found, in any layer or by the validation, is
+// reported in the returned error. flag.ErrHelp is returned when args ask for the usage.
+func loadConfig(args []string, getenv func(string) string) (config, loadOptions, []configEntry, error) {
+	var cfg config
+	var opts loadOptions
+	fs := newFlagSet(&cfg, &opts)
+
+	// The flags are parsed first to find the config file and which settings they set, they are parsed again
+	// once the other layers are applied so that they take precedence
+	var scratch config
+	var scratchOpts loadOptions
+	firstPass := newFlagSet(&scratch, &scratchOpts)
+	firstPass.SetOutput(io.Discard)
+	if err := firstPass.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.Usage()
+		}
+		return cfg, opts, nil, err
+	}
+
+	if scratchOpts.file == "" {
+		scratchOpts.file = getenv(settings.EnvPrefix + "CONFIG")
+	}
+
+	sources, failed, problems := applyLayers(fs, scratchOpts.file, getenv)
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, opts, nil, err
+	}
+	opts.file = scratchOpts.file
+	firstPass.Visit(func(f *flag.Flag) { sources[f.Name] = "flag" })
+
+	v := validator.New()
+	validateConfig(v, cfg)
+	for name, message := range v.Errors {
+		if failed[name] {
+			continue
+		}
+		problems = append(problems, name+": "+message)
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return cfg, opts, nil, fmt.Errorf("invalid configuration:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+
+	var entries []configEntry
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		source, ok := sources[f.Name]
+		if !ok {
+			source = "default"
+		}
+		entries = append(entries, configEntry{name: f.Name, value: f.Value.String(), source: source})
+	})
+	return cfg, opts, entries, nil
+}
+
+// applyLayers sets the settings of the config file, when there is one, then of the environment. It returns
+// the source of every setting set, the settings which failed to parse and the problems found.
+func applyLayers(fs *flag.FlagSet, file string, getenv func(string) string) (sources map[string]string, failed map[string]bool, problems []string) {
+	// A setting which fails to parse is reported once, its validation is skipped
+	sources = make(map[string]string)
+	failed = make(map[string]bool)
+	if file != "" {
+		problems = append(problems, applyConfigFile(fs, file, sources, failed)...)
+	}
+	problems = append(problems, applyEnv(fs, getenv, sources, failed)...)
+	return sources, failed, problems
+}
+
+// applyConfigFile sets the settings of the file, see [settings.ReadFile] for its format
+func applyConfigFile(fs *flag.FlagSet, path string, sources map[string]string, failed map[string]bool) []string {
+	values, err := settings.ReadFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	for name, value := range values {
+		if fs.Lookup(name) == nil || name == "config" || name == "print-config" {
+			problems = append(problems, fmt.Sprintf("config file %s: unknown setting %s", path, name))
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			problems = append(problems, fmt.Sprintf("config file %s: %s: invalid value %q: %s", path, name, value, err))
+			failed[name] = true
+			continue
+		}
+		sources[name] = "file " + path
+	}
+	return problems
+}
+
+// applyEnv sets the settings of the GREENLIGHT_* environment variables, a secret setting may be read from
+// the file of its _FILE variable instead
+func applyEnv(fs *flag.FlagSet, getenv func(string) string, sources map[string]string, failed map[string]bool) []string {
+	var problems []string
+	set := func(name, value, source string) {
+		if err := fs.Set(name, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid value: %s", source, err))
+			failed[name] = true
+			return
+		}
+		delete(failed, name)
+		sources[name] = "env " + source
+	}
+
+	for legacy, name := range legacyEnv {
+		if value := getenv(legacy); value != "" && getenv(settings.EnvName(name)) == "" && getenv(settings.EnvName(name)+"_FILE") == "" {
+			slog.Warn("deprecated environment variable, use "+settings.EnvName(name)+" instead", "variable", legacy)
+			set(name, value, legacy)
+		}
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		if slices.Contains(secretSettings, f.Name) {
+			value, source, err := settings.SecretEnv(getenv, f.Name)
+			if err != nil {
+				problems = append(problems, err.Error())
+				return
+			}
+			if source != "" {
+				set(f.Name, value, source)
+			}
+			return
+		}
+		key := settings.EnvName(f.Name)
+		value := getenv(key)
+		if value != "" {
+			set(f.Name, value, key)
+		}
+	})
+	return problems
+}
+
+// validateConfig checks every setting, the errors are keyed by setting name
+func validateConfig(v *validator.Validator, cfg config) {
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	if cfg.metricsAddr != "" {
+		_, port, err := net.SplitHostPort(cfg.metricsAddr)
+		v.Check(err == nil, "metrics-addr", "must be a host:port address such as localhost:9090")
+		v.Check(port != strconv.Itoa(cfg.port), "metrics-addr", "must not use the port of the API")
+	}
+	v.Check(v.In(cfg.env, []string{"development", "staging", "production"}), "env", "must be development, staging or production")
+	v.Check(v.In(cfg.tracing.exporter, []string{tracingExporterNone, tracingExporterStdout, tracingExporterOTLPFile}), "tracing-exporter", "must be none, stdout or otlp-file")
+	if cfg.tracing.exporter == tracingExporterOTLPFile {
+		v.Check(cfg.tracing.file != "", "tracing-file", "must be provided with the otlp-file exporter")
+	}
+
+	v.Check(v.In(cfg.db.backend, []string{backendPostgres, backendMemory}), "db-backend", "must be postgres or memory")
+	if cfg.db.backend == backendPostgres {
+		v.Check(cfg.db.dsn != "", "db-dsn", "must be provided with the postgres backend")
+	}
+	v.Check(cfg.db.maxOpenConns > 0, "db-max-open-conn", "must be positive")
+	v.Check(cfg.db.maxIdleTime >= 0, "db-max-idle-time", "must not be negative")
+	v.Check(cfg.db.queryTimeout > 0, "db-query-timeout", "must be positive")
+
+	v.Check(cfg.limiter.rps > 0, "limiter-rps", "must be positive")
+	v.Check(cfg.limiter.burst > 0, "limiter-burst", "must be positive")
+
+	v.Check(cfg.smtp.host != "", "smtp-host", "must be provided")
+	v.Check(cfg.smtp.port > 0 && cfg.smtp.port <= 65535, "smtp-port", "must be between 1 and 65535")
+	if cfg.smtp.sender != "" {
+		v.Check(validator.Matches(cfg.smtp.sender, validator.EmailRX), "smtp-sender", "must be a valid email address")
+	}
+	// The emails are only required to be sent outside of development
+	if cfg.env != "development" {
+		v.Check(cfg.smtp.username != "", "smtp-username", "must be provided in "+cfg.env)
+		v.Check(cfg.smtp.password != "", "smtp-password", "must be provided in "+cfg.env)
+		v.Check(cfg.smtp.sender != "", "smtp-sender", "must be provided in "+cfg.env)
+	}
+
+	for _, origin := range cfg.cors.trustedOrigins {
+		u, err := url.Parse(origin)
+		v.Check(err == nil && u.Scheme != "" && u.Host != "" && u.Path == "", "cors-trusted-origins", "must be origins such as https://example.com")
+	}
+
+	v.Check(cfg.storage.dir != "", "storage-dir", "must be provided")
+	v.Check(cfg.storage.baseURL != "", "storage-base-url", "must be provided")
+	v.Check(cfg.recommendations.refreshInterval >= 0, "recommendations-refresh-interval", "must not be negative")
+}
+
+// dsnSecretParams are the parameters of a DSN URL holding a secret, libpq also reads them from the query
+var dsnSecretParams = []string{"password", "sslpassword"}
+
+// redact hides the value of a secret setting. Only the passwords of a DSN URL are hidden, a DSN in the
+// keyword/value format is hidden entirely.
+func redact(name, value string) string {
+	if value == "" || !slices.Contains(secretSettings, name) {
+		return value
+	}
+	if name == "db-dsn" {
+		if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+			query := u.Query()
+			for param := range query {
+				if slices.Contains(dsnSecretParams, strings.ToLower(param)) {
+					query.Set(param, "xxxxx")
+				}
+			}
+			u.RawQuery = query.Encode()
+			return u.Redacted()
+		}
+	}
+	return "xxxxx"
+}
+
+// printConfig writes the effective config with the source of every value
+func printConfig(w io.Writer, entries []configEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.name, redact(entry.name, entry.value), entry.source)
+	}
+	return tw.Flush()
+}
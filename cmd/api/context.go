@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 )
@@ -12,7 +13,24 @@ type contextKey string
 // use this key as constant to get the user key from request context later
 const userContextKey = contextKey("user")
 
+// requestInfoContextKey holds the [requestInfo] of the request
+const requestInfoContextKey = contextKey("requestInfo")
+
+// requestInfo is filled while the request goes down the handlers and read once it completes, e.g. by the
+// metrics and tracing middleware. It is shared by pointer as the router passes a new request to the handlers.
+type requestInfo struct {
+	requestID string // From the X-Request-ID header of the client, or generated
+	start     time.Time
+	route     string          // Pattern of the matched route, empty when no route matched
+	user      *data.User      // Set by the authenticate middleware, nil when the request didn't reach it
+	response  *responseWriter // Status and size of the response
+}
+
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	// Also kept in the request info for the access log, which only sees the request before authentication
+	if info := app.contextGetRequestInfo(r); info != nil {
+		info.user = user
+	}
 	ctx := context.WithValue(r.Context(), userContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -26,3 +44,23 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (app *application) contextSetRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestInfo returns nil when the request didn't go through [application.trackRequest]
+func (app *application) contextGetRequestInfo(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoContextKey).(*requestInfo)
+	return info
+}
+
+// contextGetRequestID returns the ID of the request, empty when the request didn't go through
+// [application.trackRequest]
+func (app *application) contextGetRequestID(r *http.Request) string {
+	if info := app.contextGetRequestInfo(r); info != nil {
+		return info.requestID
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listMovieCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Make sure the movie exists so that we can tell apart a movie without credits from a missing movie
+	if _, err := app.models.Movie.Get(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	credits, err := app.models.Credit.GetAllForMovie(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"credits": credits}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMovieCreditHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		PersonID      int64  `json:"person_id"`
+		Role          string `json:"role"`
+		CharacterName string `json:"character_name"`
+		BillingOrder  int32  `json:"billing_order"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	credit := &data.Credit{
+		MovieID:       id,
+		PersonID:      input.PersonID,
+		Role:          input.Role,
+		CharacterName: input.CharacterName,
+		BillingOrder:  input.BillingOrder,
+	}
+
+	v := validator.New()
+	if data.ValidateCredit(v, credit); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if _, err := app.models.Movie.Get(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Credit.Create(r.Context(), credit); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("person_id", "person does not exist")
+			app.failValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrDuplicateCredit):
+			v.AddError("role", "the person is already credited with this role on the movie")
+			app.failValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"credit": credit}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieCreditHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	creditID, err := app.readNamedIDParam(r, "credit_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Credit.Delete(r.Context(), movieID, creditID); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "credit successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,21 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
 )
 
+// statusClientClosedRequest is the non standard status (from nginx) of a request the client gave up on
+const statusClientClosedRequest = 499
+
 func (app *application) logError(r *http.Request, err error) {
-	errLogger := app.logger.With("request_url", r.URL.String(), "request_method", r.Method)
-	errLogger.Error(err.Error())
+	errLogger := app.logger.With("request_id", app.contextGetRequestID(r), "request_url", r.URL.String(), "request_method", r.Method)
+	errLogger.ErrorContext(r.Context(), err.Error())
 }
 
 // The errorResponse() method is a generic helper for sending JSON-formatted error
 // messages to the client with a given status code. Note that we're using an interface{}
 // type for the message parameter, rather than just a string type, as this gives us
-// more flexibility over the values that we can include in the response.
+// more flexibility over the values that we can include in the response. The request ID is included so that
+// users can quote it to support.
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, errMsg any) {
 	env := envelop{"error": errMsg}
+	if requestID := app.contextGetRequestID(r); requestID != "" {
+		env["request_id"] = requestID
+	}
 	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
 		app.logError(r, err)
@@ -28,11 +40,44 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 // errorResponse() helper to send a 500 Internal Server Error status code and JSON
 // response (containing a generic error message) to the client.
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// A query that did not complete in time or was canceled, or an invalid sort, is not a failure of the server
+	switch {
+	case errors.Is(err, models.ErrCanceled):
+		app.canceledResponse(w, r, err)
+		return
+	case errors.Is(err, models.ErrQueryTimeout):
+		app.queryTimeoutResponse(w, r, err)
+		return
+	// The handlers validate the sort against its safe list, this only catches one which did not
+	case errors.Is(err, data.ErrInvalidSort):
+		app.failValidationResponse(w, r, map[string]string{"sort": err.Error()})
+		return
+	}
+
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
+// canceledResponse is sent when the database work of the request was canceled. Either the client went away,
+// then the 499 status is only meant for the logs, or the server is shutting down and the client should retry.
+func (app *application) canceledResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.WarnContext(r.Context(), "request canceled", "request_id", app.contextGetRequestID(r), "request_url", r.URL.String(), "request_method", r.Method, "err", err.Error())
+	if errors.Is(context.Cause(r.Context()), errShuttingDown) {
+		message := "the server is shutting down, please try again"
+		app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+		return
+	}
+	app.errorResponse(w, r, statusClientClosedRequest, "the request was canceled")
+}
+
+// queryTimeoutResponse is sent when the database work of the request ran longer than the query timeout
+func (app *application) queryTimeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.WarnContext(r.Context(), "query timeout", "request_id", app.contextGetRequestID(r), "request_url", r.URL.String(), "request_method", r.Method, "err", err.Error())
+	message := "the server is currently unable to handle your request, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
 // The notFoundResponse() method will be used to send a 404 Not Found status code and
 // JSON response to the client.
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
@@ -87,3 +132,20 @@ func (app *application) notPermitResponse(w http.ResponseWriter, r *http.Request
 	message := "your account does not have necessary permissions to access this resources"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+// databaseRequiredResponse is sent for the features the in-memory backend does not provide
+func (app *application) databaseRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this resource is not available with the memory backend"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// featureDisabledResponse is sent for the features switched off by their feature-* setting
+func (app *application) featureDisabledResponse(w http.ResponseWriter, r *http.Request, feature string) {
+	message := fmt.Sprintf("the %s feature is currently disabled", feature)
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+func (app *application) duplicateReviewResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you have already reviewed this movie, update your existing review instead"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// lookupMovieHandler finds a movie by one of its external IDs, e.g. /v1/movies/lookup?imdb=tt0111161
+func (app *application) lookupMovieHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var externalID data.ExternalID
+	for _, source := range data.ExternalSources {
+		if id := app.readString(qs, source, ""); id != "" {
+			externalID = data.ExternalID{Source: source, ID: id}
+			break
+		}
+	}
+
+	v := validator.New()
+	if externalID.Source == "" {
+		v.AddError("imdb", "either imdb or tmdb must be provided")
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+	if data.ValidateExternalID(v, &externalID); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	id, err := app.models.ExternalID.GetMovieID(r.Context(), externalID.Source, externalID.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.showMovie(w, r, id)
+}
+
+func (app *application) putMovieExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ID string `json:"id"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	externalID := &data.ExternalID{
+		MovieID: id,
+		Source:  httprouter.ParamsFromContext(r.Context()).ByName("source"),
+		ID:      input.ID,
+	}
+
+	v := validator.New()
+	if data.ValidateExternalID(v, externalID); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.ExternalID.Put(r.Context(), externalID); err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateExternalID):
+			v.AddError("id", "this id already belongs to another movie")
+			app.failValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"external_id": externalID}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	source := httprouter.ParamsFromContext(r.Context()).ByName("source")
+	if err := app.models.ExternalID.Delete(r.Context(), id, source); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "external id successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// attachExternalIDs loads the external IDs of the movies
+func (app *application) attachExternalIDs(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	byMovie, err := app.models.ExternalID.GetAllForMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		movie.ExternalIDs = byMovie[movie.ID]
+	}
+	return nil
+}
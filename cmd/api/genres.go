@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genre.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"genres": genres}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	genre, err := app.models.Genre.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"genre": genre}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	index, err := app.models.Genre.Index(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{Slug: input.Slug, Name: input.Name, Aliases: input.Aliases}
+
+	v := validator.New()
+	data.ValidateGenre(v, genre, index)
+	_, exists := index[genre.Slug]
+	if v.Check(!exists, "slug", "a genre with this slug already exists"); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Genre.Create(r.Context(), genre); err != nil {
+		if errors.Is(err, models.ErrDuplicateGenre) {
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failValidationResponse(w, r, v.Errors)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.genres.invalidate()
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/genres/%d", genre.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"genre": genre}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateGenreHandler renames a genre or changes its aliases, renaming the slug also renames it in every movie
+func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	genre, err := app.models.Genre.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	previousSlug := genre.Slug
+
+	// We use pointers here for the input in order to support partial update
+	var input struct {
+		Slug    *string  `json:"slug"`
+		Name    *string  `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Slug != nil {
+		genre.Slug = *input.Slug
+	}
+	if input.Name != nil {
+		genre.Name = *input.Name
+	}
+	if input.Aliases != nil {
+		genre.Aliases = input.Aliases
+	}
+
+	index, err := app.models.Genre.Index(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// The genre being updated must not conflict with itself
+	for key, slug := range index {
+		if slug == previousSlug {
+			delete(index, key)
+		}
+	}
+
+	v := validator.New()
+	if data.ValidateGenre(v, genre, index); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Genre.Update(r.Context(), genre, previousSlug); err != nil {
+		switch {
+		case errors.Is(err, models.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.genres.invalidate()
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"genre": genre}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Genre.Delete(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrGenreInUse):
+			app.errorResponse(w, r, http.StatusConflict, "the genre is still used by some movies, remove it from them first")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.genres.invalidate()
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "genre successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// genreIndexTTL bounds how long the cached genre index is used, the changes made through another replica of
+// the API are only seen once it expires
+const genreIndexTTL = time.Minute
+
+// genreCache holds the genre index between requests, the genre handlers invalidate it
+type genreCache struct {
+	mu       sync.Mutex
+	index    data.GenreIndex // nil when invalidated
+	loadedAt time.Time
+	// generation is incremented by invalidate, so that an index loaded before a change is not cached
+	generation int
+}
+
+// invalidate makes the next call to [application.genreIndex] load the genres again
+func (c *genreCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index = nil
+	c.generation++
+}
+
+// genreIndex returns the index of the managed genres, which is shared and must not be modified
+func (app *application) genreIndex(ctx context.Context) (data.GenreIndex, error) {
+	c := &app.genres
+	c.mu.Lock()
+	index, generation := c.index, c.generation
+	fresh := index != nil && time.Since(c.loadedAt) < genreIndexTTL
+	c.mu.Unlock()
+	if fresh {
+		return index, nil
+	}
+
+	// The genres are loaded without holding the lock, so that a slow query only delays the requests which
+	// need it. Concurrent misses may load the index more than once, the last one is kept.
+	index, err := app.models.Genre.Index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.generation == generation {
+		c.index, c.loadedAt = index, time.Now()
+	}
+	c.mu.Unlock()
+	return index, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
@@ -39,6 +41,25 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return defaultValue
 }
 
+// readTime reads a timestamp from the query string. Both RFC 3339 timestamps and plain dates
+// (2006-01-02) are accepted. If the key does not exist the defaultValue is returned.
+func (app *application) readTime(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			v.AddError(key, "must be a RFC 3339 timestamp or a date in YYYY-MM-DD format")
+			return defaultValue
+		}
+		return t
+	}
+	return defaultValue
+}
+
 // readCommaQuery helper reads a string value from the query string and then splits it
 // into a slice on the comma character. If no matching key could be found, it returns
 // the provided default value.
@@ -52,11 +73,17 @@ func (app *application) readCommaQuery(qs url.Values, key string, defaultValue [
 }
 
 func (app *application) readIDParams(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+// readNamedIDParam reads a positive integer ID from the named URL parameter, for routes with more than one ID
+// such as /v1/movies/:id/credits/:credit_id
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 	return id, nil
 }
@@ -90,6 +117,9 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data any, h
 	return nil
 }
 
+// errEmptyBody is returned by readJSON for a request without a body, which some handlers accept
+var errEmptyBody = errors.New("body must not be empty")
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	// Limit the json read to 1mb
 	maxBytesAllow := 1_048_576
@@ -131,7 +161,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 		// check for this with errors.Is() and return a plain-English error message
 		// instead.
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return errEmptyBody
 
 		// If the JSON contains a field which cannot be mapped to the target destination
 		// then Decode() will now return an error message in the format "json: unknown
@@ -169,6 +199,34 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 	return nil
 }
 
+// selectFields implements sparse fieldsets: it returns the JSON representation of src reduced to the
+// requested fields. If no field is requested, src is returned untouched. The fields must already be
+// validated against the safe list of the resource. Pass a pointer as src so that pointer receiver
+// json.Marshaler such as [data.Runtime] are still used.
+func (app *application) selectFields(src any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return src, nil
+	}
+
+	js, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(js, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		// Fields with omitempty may be missing from the encoded value, in which case they are skipped as well
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
+
 func (app *application) failValidationResponse(w http.ResponseWriter, r *http.Request, err map[string]string) {
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, err)
 }
@@ -177,9 +235,11 @@ func (app *application) failValidationResponse(w http.ResponseWriter, r *http.Re
 func (app *application) background(fn func()) {
 	// Use wait group to gracefully shutdown background job
 	app.wg.Add(1)
+	app.metrics.background.Inc("task")
 
 	go func() {
 		defer app.wg.Done()
+		defer app.metrics.background.Dec("task")
 
 		defer func() {
 			if err := recover(); err != nil {
@@ -189,3 +249,13 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// sendEmail sends the email and records its result in the metrics
+func (app *application) sendEmail(ctx context.Context, recipient, templateFile string, data map[string]any) error {
+	if err := app.mailer.Send(ctx, recipient, templateFile, data); err != nil {
+		app.metrics.mailSends.Inc("failure")
+		return err
+	}
+	app.metrics.mailSends.Inc("success")
+	return nil
+}
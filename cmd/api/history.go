@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// recordWatchHandler records that the current user watched the movie. The body is optional, without
+// a watched_at timestamp the view is recorded at the current time.
+func (app *application) recordWatchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		WatchedAt *time.Time `json:"watched_at"`
+	}
+	// The body is optional, a chunked request doesn't tell its length so it is read to find out
+	if err := app.readJSON(w, r, &input); err != nil && !errors.Is(err, errEmptyBody) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event := &data.WatchEvent{UserID: app.contextGetUser(r).ID, MovieID: id, WatchedAt: time.Now()}
+	if input.WatchedAt != nil {
+		event.WatchedAt = *input.WatchedAt
+	}
+
+	v := validator.New()
+	if data.ValidateWatchEvent(v, event); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.History.Create(r.Context(), event); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"watch": event}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWatchHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	v := validator.New()
+
+	qs := r.URL.Query()
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-watched_at")
+	filters.SortSafeList = []string{"watched_at", "-watched_at"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	history, metadata, err := app.models.History.GetAllForUser(r.Context(), app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "history": history}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showWatchStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.models.History.GetStats(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"stats": stats}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/images"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/storage"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// maxImageBytes is the maximum size of an uploaded image
+const maxImageBytes = 10 << 20
+
+// uploadMovieImageHandler accepts a multipart form with an "image" file (JPEG, PNG or WebP) and a "kind"
+// (poster or still). The original is stored together with resized JPEG thumbnails.
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if _, err := app.models.Movie.Get(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.parseImageForm(w, r) {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	image := &data.MovieImage{MovieID: id, Kind: r.FormValue("kind")}
+
+	v := validator.New()
+	if data.ValidateMovieImage(v, image); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stored, err := app.storeFormImage(r, v, fmt.Sprintf("movie-%d", id))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+	image.StoredImage = *stored
+
+	if err := app.models.Image.Create(r.Context(), image); err != nil {
+		app.deleteStoredImage(r, image.StoredImage)
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.fillImageURLs(&image.StoredImage)
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"image": image}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	imageID, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	image, err := app.models.Image.Delete(r.Context(), movieID, imageID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.deleteStoredImage(r, image.StoredImage)
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "image successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// serveImageHandler serves the stored images. It does not require authentication so that the URLs can be
+// used directly in <img> tags. Stored objects never change, so they can be cached for as long as possible.
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")
+
+	f, info, err := app.storage.Open(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+info.Key+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// ServeContent picks the Content-Type from the key extension and handles the conditional and range requests
+	http.ServeContent(w, r, info.Key, info.ModTime, f)
+}
+
+// attachImages loads the images of the movies and fills in their URLs
+func (app *application) attachImages(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	byMovie, err := app.models.Image.GetAllForMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		movie.Images = byMovie[movie.ID]
+		for i := range movie.Images {
+			app.fillImageURLs(&movie.Images[i].StoredImage)
+		}
+	}
+	return nil
+}
+
+func (app *application) fillImageURLs(image *data.StoredImage) {
+	image.URL = app.storage.URL(image.Key)
+	image.Thumbnails = make(map[string]string, len(image.ThumbnailKeys))
+	for name, key := range image.ThumbnailKeys {
+		image.Thumbnails[name] = app.storage.URL(key)
+	}
+}
+
+// parseImageForm parses the multipart form of an image upload. It writes the error response and returns
+// false if the form is invalid, otherwise the caller must remove the form files with r.MultipartForm.RemoveAll.
+func (app *application) parseImageForm(w http.ResponseWriter, r *http.Request) bool {
+	// Leave some room for the multipart boundaries and the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxImageBytes))
+			return false
+		}
+		app.badRequestResponse(w, r, fmt.Errorf("body must be a valid multipart form: %w", err))
+		return false
+	}
+	return true
+}
+
+// storeFormImage decodes the "image" file (JPEG, PNG or WebP) of a parsed multipart form and stores it
+// together with resized JPEG thumbnails, under keys starting with keyPrefix. An invalid image is reported
+// in v and nothing is stored.
+func (app *application) storeFormImage(r *http.Request, v *validator.Validator, keyPrefix string) (*data.StoredImage, error) {
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		v.AddError("image", "must be provided")
+		return nil, nil
+	}
+	defer file.Close()
+
+	if v.Check(header.Size <= maxImageBytes, "image", fmt.Sprintf("must not be larger than %d bytes", maxImageBytes)); !v.Valid() {
+		return nil, nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(file, maxImageBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, contentType, err := images.Decode(content)
+	if err != nil {
+		switch {
+		case errors.Is(err, images.ErrUnsupportedType):
+			v.AddError("image", "must be a JPEG, PNG or WebP image")
+		case errors.Is(err, images.ErrTooManyPixels):
+			v.AddError("image", "dimensions are too large")
+		default:
+			v.AddError("image", "could not be decoded")
+		}
+		return nil, nil
+	}
+
+	image := &data.StoredImage{
+		ContentType:   contentType,
+		Width:         int32(decoded.Bounds().Dx()),
+		Height:        int32(decoded.Bounds().Dy()),
+		Size:          int64(len(content)),
+		ThumbnailKeys: make(map[string]string, len(data.ThumbnailWidths)),
+	}
+
+	// Keys are random so that a stored image never changes, which lets us cache it forever
+	baseKey := fmt.Sprintf("%s-%s", keyPrefix, strings.ToLower(rand.Text()))
+	image.Key = baseKey + images.Extensions[contentType]
+
+	if err := app.storage.Put(r.Context(), image.Key, bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+
+	for name, width := range data.ThumbnailWidths {
+		var buf bytes.Buffer
+		if err := images.EncodeJPEG(&buf, images.Thumbnail(decoded, width)); err != nil {
+			app.deleteStoredImage(r, *image)
+			return nil, err
+		}
+		key := fmt.Sprintf("%s-%s.jpg", baseKey, name)
+		if err := app.storage.Put(r.Context(), key, &buf); err != nil {
+			app.deleteStoredImage(r, *image)
+			return nil, err
+		}
+		image.ThumbnailKeys[name] = key
+	}
+	return image, nil
+}
+
+// deleteStoredImage removes the files of the image. Failing to remove a file only leaves an orphan behind,
+// so errors are logged and ignored. The removal is not canceled with the request, the database change it
+// follows is already done even if the client has gone away.
+func (app *application) deleteStoredImage(r *http.Request, image data.StoredImage) {
+	ctx := context.WithoutCancel(r.Context())
+	for _, key := range image.Keys() {
+		if err := app.storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			app.logError(r, err)
+		}
+	}
+}
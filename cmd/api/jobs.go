@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+)
+
+// errJobDisabled is returned by the run of a job whose feature is switched off, the run is skipped
+var errJobDisabled = errors.New("job disabled")
+
+// scheduleJobs starts the jobs that run periodically inside the API process
+func (app *application) scheduleJobs() {
+	if app.config.recommendations.refreshInterval > 0 {
+		app.schedule("refresh movie similarities", app.config.recommendations.refreshInterval, app.featureJob("recommendations", app.models.Recommendation.RefreshSimilarities))
+	}
+}
+
+// featureJob runs fn only while the feature is switched on. The feature is checked on every run, so that a
+// reload switching it off or on again stops or resumes the job.
+func (app *application) featureJob(feature string, fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !app.reloadable().features.enabled(feature) {
+			return errJobDisabled
+		}
+		return fn(ctx)
+	}
+}
+
+// schedule runs [fn] right away and then every interval until the server shuts down. Runs never overlap,
+// if a run takes longer than the interval the next one starts as soon as it finishes. Like [background],
+// the job is tracked by the wait group so that shutdown waits for the current run, whose context is canceled
+// by the shutdown so that it stops early.
+func (app *application) schedule(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	app.wg.Add(1)
+	app.metrics.background.Inc("job")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	go func() {
+		<-app.shutdown
+		cancel(errShuttingDown)
+	}()
+
+	go func() {
+		defer app.wg.Done()
+		defer app.metrics.background.Dec("job")
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			app.runJob(ctx, name, fn)
+
+			select {
+			case <-ticker.C:
+			case <-app.shutdown:
+				app.logger.Info("stopping scheduled job", "job", name)
+				return
+			}
+		}
+	}()
+}
+
+// runJob executes a single run of a scheduled job, a panic is recovered so that the schedule keeps going
+func (app *application) runJob(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Error(fmt.Errorf("%s", err).Error(), "job", name)
+		}
+	}()
+
+	start := time.Now()
+	if err := fn(ctx); err != nil {
+		switch {
+		case errors.Is(err, models.ErrLocked):
+			app.logger.Info("scheduled job skipped, another instance is running it", "job", name)
+			return
+		case errors.Is(err, errJobDisabled):
+			app.logger.Debug("scheduled job skipped, its feature is switched off", "job", name)
+			return
+		case ctx.Err() != nil:
+			app.logger.Warn("scheduled job canceled", "job", name, "cause", context.Cause(ctx).Error())
+			return
+		}
+		app.logger.Error(err.Error(), "job", name)
+		return
+	}
+	app.logger.Info("scheduled job completed", "job", name, "duration", time.Since(start))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleCanceledOnShutdown(t *testing.T) {
+	app := newTestApplication(t)
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	app.schedule("blocking job", time.Hour, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return ctx.Err()
+	})
+
+	<-started
+	close(app.shutdown)
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job is still running after the shutdown")
+	}
+	if err := <-cause; !errors.Is(err, errShuttingDown) {
+		t.Errorf("got cause %v, want errShuttingDown", err)
+	}
+}
+
+func TestFeatureJobFollowsReloads(t *testing.T) {
+	app := newTestApplication(t)
+	runs := 0
+	job := app.featureJob("recommendations", func(ctx context.Context) error {
+		runs++
+		return nil
+	})
+
+	steps := []struct {
+		enabled bool
+		wantErr error
+		runs    int
+	}{
+		{true, nil, 1},
+		{false, errJobDisabled, 1},
+		{true, nil, 2},
+	}
+	for _, step := range steps {
+		reloaded := *app.reloadable()
+		reloaded.features.recommendations = step.enabled
+		app.dynamic.Store(&reloaded)
+
+		if err := job(context.Background()); !errors.Is(err, step.wantErr) {
+			t.Errorf("enabled %t: error = %v, want %v", step.enabled, err, step.wantErr)
+		}
+		if runs != step.runs {
+			t.Errorf("enabled %t: got %d runs, want %d", step.enabled, runs, step.runs)
+		}
+	}
+}
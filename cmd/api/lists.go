@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) showWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.List.GetWatchlist(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.writeListWithItems(w, r, list)
+}
+
+func (app *application) addWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.List.GetWatchlist(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.addListItem(w, r, list)
+}
+
+func (app *application) updateWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.List.GetWatchlist(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.updateListItem(w, r, list)
+}
+
+func (app *application) removeWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.List.GetWatchlist(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.removeListItem(w, r, list)
+}
+
+// listListsHandler returns the custom lists of the current user
+func (app *application) listListsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	v := validator.New()
+
+	qs := r.URL.Query()
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "id")
+	filters.SortSafeList = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	lists, metadata, err := app.models.List.GetAllForUser(r.Context(), app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "lists": lists}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	list := &data.List{
+		UserID:      app.contextGetUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Public:      input.Public,
+	}
+
+	v := validator.New()
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.List.Create(r.Context(), list); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d", list.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"list": list}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showListHandler does not require authentication, public lists can be read by anonymous users
+// while private lists are only visible to their owner
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	list, err := app.models.List.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	isOwner := !user.IsAnonymousUser() && list.UserID == user.ID
+	// Respond with not found rather than forbidden so that private lists can't be discovered
+	if list.Watchlist || (!list.Public && !isOwner) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.writeListWithItems(w, r, list)
+}
+
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnedList(w, r)
+	if !ok {
+		return
+	}
+
+	// We use pointers here for the input in order to support partial update
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Public      *bool   `json:"public"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		list.Name = *input.Name
+	}
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+	if input.Public != nil {
+		list.Public = *input.Public
+	}
+
+	v := validator.New()
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.List.Update(r.Context(), list); err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"list": list}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnedList(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.models.List.Delete(r.Context(), list.ID); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "list successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addListItemHandler(w http.ResponseWriter, r *http.Request) {
+	if list, ok := app.readOwnedList(w, r); ok {
+		app.addListItem(w, r, list)
+	}
+}
+
+func (app *application) updateListItemHandler(w http.ResponseWriter, r *http.Request) {
+	if list, ok := app.readOwnedList(w, r); ok {
+		app.updateListItem(w, r, list)
+	}
+}
+
+func (app *application) removeListItemHandler(w http.ResponseWriter, r *http.Request) {
+	if list, ok := app.readOwnedList(w, r); ok {
+		app.removeListItem(w, r, list)
+	}
+}
+
+// readOwnedList reads the list from the :id parameter and makes sure it belongs to the current user.
+// If it does not, a response is already sent and false is returned.
+func (app *application) readOwnedList(w http.ResponseWriter, r *http.Request) (*data.List, bool) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	list, err := app.models.List.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	// The watchlist is only managed through /v1/users/me/watchlist
+	if list.Watchlist || list.UserID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	return list, true
+}
+
+func (app *application) writeListWithItems(w http.ResponseWriter, r *http.Request, list *data.List) {
+	items, err := app.models.List.GetItems(r.Context(), list.ID, app.ratingLimit(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	list.Items = items
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"list": list}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addListItem(w http.ResponseWriter, r *http.Request, list *data.List) {
+	var input struct {
+		MovieID  int64  `json:"movie_id"`
+		Position int32  `json:"position"`
+		Note     string `json:"note"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	item := &data.ListItem{ListID: list.ID, MovieID: input.MovieID, Position: input.Position, Note: input.Note}
+
+	v := validator.New()
+	if data.ValidateListItem(v, item); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.List.AddItem(r.Context(), item); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("movie_id", "movie does not exist")
+			app.failValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrDuplicateListItem):
+			v.AddError("movie_id", "movie is already in the list")
+			app.failValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"item": item}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateListItem(w http.ResponseWriter, r *http.Request, list *data.List) {
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Position int32  `json:"position"`
+		Note     string `json:"note"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	item := &data.ListItem{ListID: list.ID, MovieID: movieID, Position: input.Position, Note: input.Note}
+
+	v := validator.New()
+	if data.ValidateListItem(v, item); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.List.UpdateItem(r.Context(), item); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"item": item}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeListItem(w http.ResponseWriter, r *http.Request, list *data.List) {
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.List.RemoveItem(r.Context(), list.ID, movieID); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "movie successfully removed from the list"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
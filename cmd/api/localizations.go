@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listMovieLocalizationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if _, err := app.models.Movie.Get(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	titles, releaseDates, err := app.models.Localization.GetAllForMovie(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"titles": titles, "release_dates": releaseDates}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Title string `json:"title"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	title := &data.LocalizedTitle{
+		MovieID: id,
+		Locale:  httprouter.ParamsFromContext(r.Context()).ByName("locale"),
+		Title:   input.Title,
+	}
+
+	v := validator.New()
+	if data.ValidateLocalizedTitle(v, title); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Localization.PutTitle(r.Context(), title); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"title": title}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	locale, ok := data.CanonicalLocale(httprouter.ParamsFromContext(r.Context()).ByName("locale"))
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Localization.DeleteTitle(r.Context(), id, locale); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "title successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieReleaseDateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Date data.Date `json:"date"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	releaseDate := &data.ReleaseDate{
+		MovieID: id,
+		Country: strings.ToUpper(httprouter.ParamsFromContext(r.Context()).ByName("country")),
+		Date:    input.Date,
+	}
+
+	v := validator.New()
+	if data.ValidateReleaseDate(v, releaseDate); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Localization.PutReleaseDate(r.Context(), releaseDate); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"release_date": releaseDate}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieReleaseDateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	country := strings.ToUpper(httprouter.ParamsFromContext(r.Context()).ByName("country"))
+	if err := app.models.Localization.DeleteReleaseDate(r.Context(), id, country); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "release date successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// localizeMovies replaces the title of the movies with their title in the language of the client, taken from
+// the lang query parameter or the Accept-Language header, and fills in their release date in the client country.
+// The original title is kept in OriginalTitle.
+func (app *application) localizeMovies(w http.ResponseWriter, r *http.Request, movies ...*data.Movie) error {
+	// The representation depends on the Accept-Language header, caches must not share it between languages
+	w.Header().Add("Vary", "Accept-Language")
+
+	locale := data.ParseLocale(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+	if locale.IsZero() || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	localizations, err := app.models.Localization.Localize(r.Context(), ids, locale)
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		localization, ok := localizations[movie.ID]
+		if !ok {
+			continue
+		}
+		if localization.Title != "" && localization.Title != movie.Title {
+			movie.OriginalTitle = movie.Title
+			movie.Title = localization.Title
+		}
+		movie.ReleaseDate = localization.ReleaseDate
+	}
+	return nil
+}
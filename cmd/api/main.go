@@ -2,111 +2,232 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 	"github.com/nguyenanhhao221/greenlight-api/internal/mailer"
 	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/storage"
+	"github.com/nguyenanhhao221/greenlight-api/internal/tracing"
 )
 
 // TODO: do this at build time rather than hard code
 const version = "1.0.0"
 
+const (
+	backendPostgres = "postgres"
+	backendMemory   = "memory"
+)
+
 type config struct {
 	port int
 	env  string
-	db   struct {
+	// metricsAddr is the address of the admin listener serving the metrics, disabled when empty
+	metricsAddr string
+	db          struct {
+		backend      string // postgres or memory, see [models.NewMemory]
 		dsn          string
 		maxOpenConns int
 		maxIdleTime  time.Duration
+		queryTimeout time.Duration
+		// migrateOnStart applies the pending migrations before serving, see the migrate subcommand
+		migrateOnStart bool
 	}
-	limiter struct {
-		rps     float64
-		burst   int
-		enabled bool
-	}
-	smtp struct {
+	// The limiter, cors, logLevel and features settings are reloaded on SIGHUP, the handlers read them
+	// through [application.reloadable] rather than from here
+	limiter  limiterConfig
+	cors     corsConfig
+	logLevel slog.Level
+	features featuresConfig
+	smtp     struct {
 		host     string
 		port     int
 		username string
 		password string
 		sender   string
 	}
+	recommendations struct {
+		refreshInterval time.Duration
+	}
+	storage struct {
+		dir     string
+		baseURL string
+	}
+	tracing struct {
+		exporter string // none, stdout or otlp-file, see [newTracer]
+		file     string
+	}
+	ratings struct {
+		// anonymousMaxRating hides the movies rated above it from anonymous users, nil for no limit
+		anonymousMaxRating *data.Certification
+	}
+}
+
+type limiterConfig struct {
+	rps     float64
+	burst   int
+	enabled bool
 }
+
+type corsConfig struct {
+	trustedOrigins []string // Any origin is allowed when empty
+}
+
+// featuresConfig holds the toggles of the features which can be switched off without a deploy
+type featuresConfig struct {
+	signup          bool
+	reviews         bool
+	recommendations bool
+}
+
 type application struct {
 	config config
-	logger *slog.Logger
-	models models.Models
-	mailer *mailer.Mailer
-	wg     sync.WaitGroup // For shutdown background go routine gracefully
+	// dynamic holds the settings reloaded on SIGHUP, see [application.reloadable]
+	dynamic atomic.Pointer[reloadableConfig]
+	// configEntries are the settings in effect, compared to the config file and environment on reload
+	configEntries []configEntry
+	logLevel      *slog.LevelVar
+	logger        *slog.Logger
+	metrics       *appMetrics
+	tracer        *tracing.Tracer
+	models        models.Models
+	mailer        *mailer.Mailer
+	// storage holds the uploaded files such as movie images
+	storage storage.Storage
+	genres  genreCache
+	wg      sync.WaitGroup // For shutdown background go routine gracefully
+	// shutdown is closed when the server starts shutting down, so that scheduled jobs stop
+	shutdown chan struct{}
 }
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		slog.Error("Error loading .env file")
+	// The .env file is optional, its variables are read like the rest of the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("Error loading .env file", "err", err.Error())
 		os.Exit(1)
 	}
-	mailPassword := os.Getenv("MAIL_SERVER_PASSWORD")
-	if mailPassword == "" {
-		slog.Error("need to set environment variable: MAIL_SERVER_PASSWORD")
-		os.Exit(1)
+
+	// The migrate subcommand only needs the database, see [runMigrate]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
 	}
 
-	var cfg config
-
-	// Get server config via cli flag
-	flag.IntVar(&cfg.port, "port", 42069, "API server port")
-	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
-	flag.StringVar(&cfg.db.dsn, "db-dsn", "postgres://greenlight@localhost/greenlight", "PostgreSQL DSN")
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conn", 25, "Max open connection pool for postgres database")
-	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 15*time.Minute, "the duration after which an idle connection will be automatically closed by the health check")
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum request per second")
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
-	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
-	// Setup for smtp configuration, credential need to be set up via MailTrap
-	flag.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")
-	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
-	flag.StringVar(&cfg.smtp.username, "smtp-username", "633e69db2a27b2", "SMTP username")
-	flag.StringVar(&cfg.smtp.password, "smtp-password", mailPassword, "SMTP password")
-	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "hao@haonguyen.tech", "SMTP sender")
-	flag.Parse()
-
-	// Initialize default slog
-	slogger := slog.Default()
-
-	// setup postgres database connection
-	slog.Info("Opening database connection using pgxpool")
-	connPool, err := openDBConnPool(cfg)
+	cfg, opts, entries, err := loadConfig(os.Args[1:], os.Getenv)
 	if err != nil {
-		slog.Error(err.Error())
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.printConfig {
+		if err := printConfig(os.Stdout, entries); err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize default slog, its level can be changed on SIGHUP. The logs of a request carry its trace ID.
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.logLevel)
+	slogger := slog.New(tracing.NewLogHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+	slog.SetDefault(slogger)
+	if opts.file != "" {
+		slog.Info("Loaded config file", "file", opts.file)
+	}
+
+	tracer, err := newTracer(cfg)
+	if err != nil {
+		slog.Error("error setting up tracing", "err", err.Error())
+		os.Exit(1)
+	}
+
+	appMetrics := newAppMetrics()
+	var appModels models.Models
+	switch cfg.db.backend {
+	case backendPostgres:
+		// setup postgres database connection
+		slog.Info("Opening database connection using pgxpool")
+		connPool, err := openDBConnPool(cfg)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		defer connPool.Close()
+		appMetrics.registerPool(connPool)
+		if cfg.db.migrateOnStart {
+			slog.Info("Applying the pending database migrations")
+			if err := migrateOnStart(connPool, slog.Default()); err != nil {
+				slog.Error("error applying the database migrations", "err", err.Error())
+				os.Exit(1)
+			}
+		}
+		appModels = models.New(models.NewDB(connPool, cfg.db.queryTimeout)) // set up basic model for database access layer
+	case backendMemory:
+		if cfg.db.migrateOnStart {
+			slog.Warn("-migrate-on-start is ignored by the memory backend")
+		}
+		slog.Warn("Using the memory backend, the data is lost on exit and the people, credits, reviews, lists and watch history are not available")
+		appModels = models.NewMemory()
+	default:
+		slog.Error("invalid db-backend, must be postgres or memory", "db-backend", cfg.db.backend)
 		os.Exit(1)
 	}
-	defer connPool.Close()
 
 	mailer, err := mailer.New(cfg.smtp.host, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
 	if err != nil {
 		slog.Error("error setting up mailer: ", "err:", err.Error())
 		os.Exit(1)
 	}
+	fileStorage, err := storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL)
+	if err != nil {
+		slog.Error("error setting up storage: ", "err:", err.Error())
+		os.Exit(1)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: slogger,
-		models: models.New(connPool), // set up basic model for database access layer
-		mailer: mailer,
+		config:        cfg,
+		configEntries: entries,
+		logLevel:      logLevel,
+		logger:        slogger,
+		metrics:       appMetrics,
+		tracer:        tracer,
+		models:        appModels,
+		mailer:        mailer,
+		storage:       fileStorage,
+		shutdown:      make(chan struct{}),
 	}
+	app.dynamic.Store(cfg.reloadable())
 
-	if err := app.serve(); err != nil {
+	err = app.serve()
+	// The spans still buffered are written before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		app.logger.Error("error shutting down tracing", "err", err.Error())
+	}
+	if err != nil {
 		app.logger.Error(err.Error())
 		os.Exit(1)
 	}
 }
 
+// hasDatabase reports whether the API runs on PostgreSQL, the in-memory backend has no people, credits,
+// reviews, lists nor watch history
+func (app *application) hasDatabase() bool {
+	return app.config.db.backend == backendPostgres
+}
+
 func setupDbConfig(cfg config) (*pgxpool.Config, error) {
 	dbConfig, err := pgxpool.ParseConfig(cfg.db.dsn)
 	if err != nil {
@@ -115,6 +236,8 @@ func setupDbConfig(cfg config) (*pgxpool.Config, error) {
 
 	dbConfig.MaxConns = int32(cfg.db.maxOpenConns)
 	dbConfig.MaxConnIdleTime = cfg.db.maxIdleTime
+	// Every query run by a traced request gets its own span
+	dbConfig.ConnConfig.Tracer = tracing.QueryTracer{}
 
 	return dbConfig, nil
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nguyenanhhao221/greenlight-api/internal/metrics"
+	"github.com/nguyenanhhao221/greenlight-api/internal/tracing"
+)
+
+// unmatchedRoute is the route label of the requests no route matched, so that unknown paths don't create a
+// series each
+const unmatchedRoute = "unmatched"
+
+// otherMethod is the method label of the requests with a non standard method, which the client picks freely
+const otherMethod = "other"
+
+// standardMethods are the methods of RFC 9110 and PATCH, the only ones given a label of their own
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// appMetrics are the metrics of the API, served on the admin listener (see the metrics-addr setting)
+type appMetrics struct {
+	registry *metrics.Registry
+
+	requests        *metrics.CounterVec   // By route, method and status
+	requestDuration *metrics.HistogramVec // By route, method and status
+	inFlight        *metrics.GaugeVec
+	rateLimited     *metrics.CounterVec
+	mailSends       *metrics.CounterVec // By result, success or failure
+	background      *metrics.GaugeVec   // By kind, task for app.background and job for the scheduled jobs
+}
+
+func newAppMetrics() *appMetrics {
+	registry := metrics.NewRegistry()
+	m := &appMetrics{
+		registry:        registry,
+		requests:        registry.NewCounterVec("greenlight_http_requests_total", "Number of HTTP requests handled, by route pattern, method and status.", "route", "method", "status"),
+		requestDuration: registry.NewHistogramVec("greenlight_http_request_duration_seconds", "Duration of the HTTP requests, by route pattern, method and status.", metrics.DefaultBuckets, "route", "method", "status"),
+		inFlight:        registry.NewGaugeVec("greenlight_http_requests_in_flight", "Number of HTTP requests being handled."),
+		rateLimited:     registry.NewCounterVec("greenlight_rate_limit_rejections_total", "Number of requests rejected by the rate limiter."),
+		mailSends:       registry.NewCounterVec("greenlight_mail_sends_total", "Number of emails sent, by result (success or failure).", "result"),
+		background:      registry.NewGaugeVec("greenlight_background_goroutines", "Number of background goroutines running, by kind (task or job).", "kind"),
+	}
+	registry.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	// The series without labels are exposed from the start rather than after their first update
+	m.inFlight.Set(0)
+	m.rateLimited.Add(0)
+	for _, result := range []string{"success", "failure"} {
+		m.mailSends.Add(0, result)
+	}
+	for _, kind := range []string{"task", "job"} {
+		m.background.Set(0, kind)
+	}
+	return m
+}
+
+// registerPool exposes the statistics of the PostgreSQL connection pool, read on every scrape
+func (m *appMetrics) registerPool(pool *pgxpool.Pool) {
+	gauges := map[string]struct {
+		help  string
+		value func(*pgxpool.Stat) float64
+	}{
+		"greenlight_db_pool_acquired_conns":     {"Number of connections currently in use.", func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }},
+		"greenlight_db_pool_idle_conns":         {"Number of idle connections in the pool.", func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) }},
+		"greenlight_db_pool_constructing_conns": {"Number of connections being established.", func(s *pgxpool.Stat) float64 { return float64(s.ConstructingConns()) }},
+		"greenlight_db_pool_total_conns":        {"Number of connections in the pool.", func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) }},
+		"greenlight_db_pool_max_conns":          {"Maximum size of the pool.", func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }},
+	}
+	for name, gauge := range gauges {
+		m.registry.NewGaugeFunc(name, gauge.help, func() float64 { return gauge.value(pool.Stat()) })
+	}
+
+	counters := map[string]struct {
+		help  string
+		value func(*pgxpool.Stat) float64
+	}{
+		"greenlight_db_pool_acquires_total":                 {"Number of connections acquired from the pool.", func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }},
+		"greenlight_db_pool_acquire_duration_seconds_total": {"Total time spent acquiring connections from the pool.", func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() }},
+		"greenlight_db_pool_empty_acquires_total":           {"Number of acquires which waited for a connection because the pool was empty.", func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }},
+		"greenlight_db_pool_canceled_acquires_total":        {"Number of acquires canceled by their context.", func(s *pgxpool.Stat) float64 { return float64(s.CanceledAcquireCount()) }},
+	}
+	for name, counter := range counters {
+		m.registry.NewCounterFunc(name, counter.help, func() float64 { return counter.value(pool.Stat()) })
+	}
+}
+
+// recordMetrics middleware records every request once it completes. It wraps recoverPanic and the rest of
+// the chain, so that the requests rejected by the middleware are counted too.
+func (app *application) recordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.metrics.inFlight.Inc()
+		defer app.metrics.inFlight.Dec()
+
+		next.ServeHTTP(w, r)
+
+		info := app.contextGetRequestInfo(r)
+		route := info.route
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := r.Method
+		if !slices.Contains(standardMethods, method) {
+			method = otherMethod
+		}
+		status := strconv.Itoa(info.response.status)
+		app.metrics.requests.Inc(route, method, status)
+		app.metrics.requestDuration.Observe(time.Since(info.start).Seconds(), route, method, status)
+	})
+}
+
+// matchedRoute records the pattern of the route for the metrics and the traces, and times the handler in a
+// span of its own. It wraps every handler of the router.
+func (app *application) matchedRoute(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if info := app.contextGetRequestInfo(r); info != nil {
+			info.route = pattern
+		}
+		ctx, span := tracing.Start(r.Context(), "handler "+pattern, tracing.SpanKindInternal)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// responseWriter records the status and the size of the response
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecordMetricsMethodLabel(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.routes()
+	for _, method := range []string{http.MethodGet, "FOO", "BAR"} {
+		serve(handler, method, "/v1/healthcheck", "", "")
+	}
+
+	var output strings.Builder
+	if _, err := app.metrics.registry.WriteTo(&output); err != nil {
+		t.Fatal(err)
+	}
+	metrics := output.String()
+	for _, want := range []string{`method="GET"`, `method="other"`} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("the metrics have no %s label:\n%s", want, metrics)
+		}
+	}
+	for _, method := range []string{"FOO", "BAR"} {
+		if strings.Contains(metrics, `method="`+method+`"`) {
+			t.Errorf("the metrics have a label for the %s method", method)
+		}
+	}
+}
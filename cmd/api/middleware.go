@@ -1,20 +1,75 @@
 package main
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/tracing"
 	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
 	"golang.org/x/time/rate"
 )
 
+// requestIDRX matches the request IDs accepted from the clients, the others are replaced so that the logs
+// can't be polluted through the header
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// trackRequest is the outermost middleware, it sets up the [requestInfo] the other middleware read once the
+// request completes. The request keeps the X-Request-ID of the client, e.g. a proxy, or gets a new one, and
+// the ID is sent back in the response.
+func (app *application) trackRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(requestID) {
+			requestID = rand.Text()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		info := &requestInfo{requestID: requestID, start: time.Now(), response: newResponseWriter(w)}
+		next.ServeHTTP(info.response, app.contextSetRequestInfo(r, info))
+	})
+}
+
+// logRequest middleware writes the access log, a line per request once it completes. It runs within the
+// span of the request so that the line carries the trace ID.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		info := app.contextGetRequestInfo(r)
+		route := info.route
+		if route == "" {
+			route = unmatchedRoute
+		}
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+		attrs := []any{
+			"request_id", info.requestID,
+			"method", r.Method,
+			"route", route,
+			"status", info.response.status,
+			"bytes", info.response.bytes,
+			"duration", time.Since(info.start),
+			"remote_ip", remoteIP,
+		}
+		if info.user != nil && !info.user.IsAnonymousUser() {
+			attrs = append(attrs, "user_id", info.user.ID)
+		}
+		app.logger.InfoContext(r.Context(), "request", attrs...)
+	})
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Create a defer function which go will always run in the event of a panic as Go unwinds the stack
@@ -54,7 +109,9 @@ func (app *application) rateLimitMiddleware(next http.Handler) http.Handler {
 	}()
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
+		// Read once so that the whole request sees the same limiter settings, even during a reload
+		limiter := app.reloadable().limiter
+		if limiter.enabled {
 			// Split to get the IP address for ip base rate limit
 			ip, _, err := net.SplitHostPort(r.RemoteAddr)
 			if err != nil {
@@ -67,12 +124,20 @@ func (app *application) rateLimitMiddleware(next http.Handler) http.Handler {
 			_, found := clients[ip]
 			if !found {
 				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
+					limiter: rate.NewLimiter(rate.Limit(limiter.rps), limiter.burst),
 				}
 			}
+			// The limiters created before a reload are moved to the new settings
+			if clients[ip].limiter.Limit() != rate.Limit(limiter.rps) {
+				clients[ip].limiter.SetLimit(rate.Limit(limiter.rps))
+			}
+			if clients[ip].limiter.Burst() != limiter.burst {
+				clients[ip].limiter.SetBurst(limiter.burst)
+			}
 			clients[ip].lastSeen = time.Now()
 			if !clients[ip].limiter.Allow() {
 				mu.Unlock()
+				app.metrics.rateLimited.Inc()
 				app.rateLimitExceededResponse(w, r)
 				return
 			}
@@ -91,7 +156,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		authorizationHeader := r.Header.Get("Authorization")
 		if authorizationHeader == "" {
-			app.logger.Info("Authorization header not found, setting user as AnonymousUser")
+			app.logger.InfoContext(r.Context(), "Authorization header not found, setting user as AnonymousUser")
 			// If header is not set, treat user as anonymous user
 			r = app.contextSetUser(r, data.AnonymousUser)
 			next.ServeHTTP(w, r)
@@ -115,7 +180,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// Validate that token actual relate to a user in database
-		user, err := app.models.User.GetUserWithToken(token, data.ScopeAuthentication)
+		user, err := app.models.User.GetUserWithToken(r.Context(), token, data.ScopeAuthentication)
 		if err != nil {
 			switch {
 			case errors.Is(err, models.ErrRecordNotFound):
@@ -129,6 +194,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		// Now that a user and token are valid, set the user in request context
 		r = app.contextSetUser(r, user)
+		tracing.SpanFromContext(r.Context()).SetAttributes(tracing.Attr("enduser.id", user.ID))
 
 		next.ServeHTTP(w, r)
 	})
@@ -155,7 +221,7 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 		user := app.contextGetUser(r)
 
 		if !user.Activated {
-			app.logger.Warn("user is not activated, preventing access to resource ", "user id", user.ID)
+			app.logger.WarnContext(r.Context(), "user is not activated, preventing access to resource ", "user id", user.ID)
 			app.inactiveAccountResponse(w, r)
 			return
 		}
@@ -166,10 +232,34 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthenticatedUser(fn)
 }
 
+// requireDatabase middleware responds with 503 Service Unavailable when the API runs on the in-memory
+// backend, which only stores the movies, users, tokens and permissions
+func (app *application) requireDatabase(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.hasDatabase() {
+			app.databaseRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// requireFeature middleware responds with 503 Service Unavailable while the feature is switched off, see
+// the feature-* settings
+func (app *application) requireFeature(feature string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.reloadable().features.enabled(feature) {
+			app.featureDisabledResponse(w, r, feature)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 func (app *application) requirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
-		userPermissions, err := app.models.Permission.GetAllForUser(user.ID)
+		userPermissions, err := app.models.Permission.GetAllForUser(r.Context(), user.ID)
 		if err != nil {
 			err = fmt.Errorf("error requirePermission when call Permission.GetAllForUser %w", err)
 			app.serverErrorResponse(w, r, err)
@@ -186,17 +276,45 @@ func (app *application) requirePermission(permission string, next http.HandlerFu
 	return app.requireActivatedUser(fn)
 }
 
+// requirePermissionUnlessAnonymous middleware lets the anonymous users through, while the authenticated users
+// need the permission as with [requirePermission]. It is meant for the public movie pages, where the
+// anonymous users only see the movies allowed by the anonymous-max-rating setting.
+func (app *application) requirePermissionUnlessAnonymous(permission string, next http.HandlerFunc) http.HandlerFunc {
+	withPermission := app.requirePermission(permission, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetUser(r).IsAnonymousUser() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		withPermission.ServeHTTP(w, r)
+	}
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
 		w.Header().Add("Vary", "Access-Control-Request-Method")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		// Without trusted origins any origin is allowed, otherwise only the listed ones are
+		origin := r.Header.Get("Origin")
+		trustedOrigins := app.reloadable().cors.trustedOrigins
+		switch {
+		case len(trustedOrigins) == 0:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(trustedOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Lets the browser apps read the request ID, e.g. to show it with an error
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		// If this if statement is satisfy, this is a pre-flight request
 		/// We need to response to this pre-flight request by setting appropriate header and status OK
 		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 			w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-ID, traceparent")
 			w.WriteHeader(http.StatusOK)
 			return
 		}
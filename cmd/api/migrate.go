@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nguyenanhhao221/greenlight-api/internal/migrate"
+	"github.com/nguyenanhhao221/greenlight-api/internal/settings"
+	"github.com/nguyenanhhao221/greenlight-api/migrations"
+)
+
+const migrateUsage = `Usage: api migrate [-config FILE] [-db-dsn DSN] COMMAND
+
+The DSN is the db-dsn setting, read like the server does from the config file, the environment
+(` + settings.EnvPrefix + `DB_DSN or ` + settings.EnvPrefix + `DB_DSN_FILE, also from .env) and the -db-dsn flag.
+
+Commands:
+  up         apply every pending migration
+  down N     revert the N last applied migrations
+  goto V     apply or revert the migrations up to version V (-1 reverts all of them)
+  status     list the migrations and whether they are applied
+  force V    set the version to V and clear the dirty flag, without running any migration
+`
+
+// runMigrate runs the migrate subcommand with the arguments following "migrate" and returns the exit code
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file, see also "+settings.EnvPrefix+"CONFIG")
+	dsn := flags.String("db-dsn", "", "PostgreSQL DSN (default the db-dsn setting of the config file and the environment)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	wantArgs := 0
+	if command == "down" || command == "goto" || command == "force" {
+		wantArgs = 1
+	}
+	if len(commandArgs) != wantArgs {
+		flags.Usage()
+		return 2
+	}
+	if *dsn == "" {
+		var err error
+		if *dsn, err = settings.DSN(*configFile, os.Getenv); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	var n int
+	if wantArgs == 1 {
+		var err error
+		if n, err = strconv.Atoi(commandArgs[0]); err != nil {
+			slog.Error("invalid argument, must be an integer", "command", command, "arg", commandArgs[0])
+			return 2
+		}
+	}
+
+	// An interrupt cancels the running migration, which leaves the version dirty
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := pgx.Connect(ctx, *dsn)
+	if err != nil {
+		slog.Error("error connecting to the database", "err", err.Error())
+		return 1
+	}
+	defer conn.Close(context.Background())
+
+	migrator, err := migrate.New(conn, migrations.FS, slog.Default())
+	if err != nil {
+		slog.Error("error reading the migrations", "err", err.Error())
+		return 1
+	}
+
+	switch command {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx, n)
+	case "goto":
+		err = migrator.Goto(ctx, n)
+	case "force":
+		err = migrator.Force(ctx, n)
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		flags.Usage()
+		return 2
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		slog.Info("no migration to run")
+		return 0
+	}
+	if err != nil {
+		slog.Error("migrate "+command+" failed", "err", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, migration := range status.Migrations {
+		applied := "no"
+		switch {
+		case migration.Applied:
+			applied = "yes"
+		case status.Dirty && migration.Version == status.Version:
+			applied = "dirty"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, applied)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case status.Version == migrate.NilVersion:
+		fmt.Println("\nno migration applied")
+	case status.Dirty:
+		fmt.Printf("\nversion %d is dirty, fix the database by hand then run: migrate force VERSION\n", status.Version)
+	default:
+		fmt.Printf("\nversion %d\n", status.Version)
+	}
+	return nil
+}
+
+// migrateOnStart applies the pending migrations on a connection of the pool before the API serves, the
+// advisory lock makes the instances of a deploy wait for the first one to complete
+func migrateOnStart(pool *pgxpool.Pool, logger *slog.Logger) error {
+	ctx := context.Background()
+	poolConn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer poolConn.Release()
+
+	migrator, err := migrate.New(poolConn.Conn(), migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+	if err := migrator.Up(ctx); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
 	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 	"github.com/nguyenanhhao221/greenlight-api/internal/models"
 	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
@@ -12,9 +14,10 @@ import (
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieFilters
 		data.Filters
+		Facets []string
+		Fields []string
 	}
 
 	validator := validator.New()
@@ -22,22 +25,98 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	qs := r.URL.Query()
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCommaQuery(qs, "genres", []string{})
+	input.GenresAny = app.readCommaQuery(qs, "genres_any", []string{})
+	input.GenresNone = app.readCommaQuery(qs, "genres_none", []string{})
+	input.YearMin = app.readInt(qs, "year_min", 0, validator)
+	input.YearMax = app.readInt(qs, "year_max", 0, validator)
+	input.RuntimeMin = app.readInt(qs, "runtime_min", 0, validator)
+	input.RuntimeMax = app.readInt(qs, "runtime_max", 0, validator)
+	input.CreatedAfter = app.readTime(qs, "created_after", time.Time{}, validator)
+	input.PersonID = int64(app.readInt(qs, "person_id", 0, validator))
+	input.CollectionID = int64(app.readInt(qs, "collection_id", 0, validator))
+	input.Facets = app.readCommaQuery(qs, "facets", []string{})
+	input.Fields = app.readCommaQuery(qs, "fields", []string{})
 	input.Page = app.readInt(qs, "page", 1, validator)
 	input.PageSize = app.readInt(qs, "page_size", 20, validator)
 	input.Sort = app.readString(qs, "sort", "id")
-	input.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.SortSafeList = []string{
+		"id", "title", "year", "runtime", "average_rating", "rating_count",
+		"-id", "-title", "-year", "-runtime", "-average_rating", "-rating_count",
+	}
+
+	genres, err := app.genreIndex(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Allow searching with aliases or display names, e.g. genres=Sci-Fi
+	input.Genres = genres.Normalize(input.Genres)
+	input.GenresAny = genres.Normalize(input.GenresAny)
+	input.GenresNone = genres.Normalize(input.GenresNone)
+
+	// Hide the movies rated above the limit of the user
+	input.MaxRating = app.ratingLimit(r)
 
+	data.ValidateMovieFilters(validator, input.MovieFilters)
+	data.ValidateFacets(validator, input.Facets)
+	data.ValidateFields(validator, input.Fields, data.MovieFieldSafeList)
 	if data.ValidateFilters(validator, input.Filters); !validator.Valid() {
 		app.failValidationResponse(w, r, validator.Errors)
 		return
 	}
-	movies, metadata, err := app.models.Movie.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movie.GetAll(r.Context(), input.MovieFilters, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "movies": movies}, nil); err != nil {
+	moviePointers := make([]*data.Movie, 0, len(movies))
+	for i := range movies {
+		moviePointers = append(moviePointers, &movies[i])
+	}
+	if err := app.attachImages(r.Context(), moviePointers...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.localizeMovies(w, r, moviePointers...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.attachCertifications(r.Context(), moviePointers...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.attachExternalIDs(r.Context(), moviePointers...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.attachCollections(r.Context(), moviePointers...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	selectedMovies := make([]any, 0, len(movies))
+	for i := range movies {
+		movie, err := app.selectFields(&movies[i], input.Fields)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		selectedMovies = append(selectedMovies, movie)
+	}
+
+	env := envelop{"metadata": metadata, "movies": selectedMovies}
+	// Facets are only computed when requested since each one requires an extra query
+	if len(input.Facets) > 0 {
+		facets, err := app.models.Movie.GetFacets(r.Context(), input.MovieFilters, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["facets"] = facets
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
@@ -56,6 +135,12 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	genres, err := app.genreIndex(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Validate user input
 	validator := validator.New()
 
@@ -65,13 +150,13 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Runtime: movieInputData.Runtime,
 		Genres:  movieInputData.Genres,
 	}
-	if data.ValidateMovie(validator, &movie); !validator.Valid() {
+	if data.ValidateMovie(validator, &movie, genres); !validator.Valid() {
 		app.failValidationResponse(w, r, validator.Errors)
 		return
 	}
 
 	// Create movie to database, Create will also update the fill the movie variable with correct data
-	err = app.models.Movie.Create(&movie)
+	err = app.models.Movie.Create(r.Context(), &movie)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -88,14 +173,31 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 }
 
 func (app *application) showMovieHanlder(w http.ResponseWriter, r *http.Request) {
+	// httprouter does not allow a static /v1/movies/lookup route next to /v1/movies/:id, so it is dispatched from here
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "lookup" {
+		app.lookupMovieHandler(w, r)
+		return
+	}
+
 	id, err := app.readIDParams(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
+	app.showMovie(w, r, id)
+}
+
+// showMovie writes the movie with the given ID, shared by the show and the lookup handlers
+func (app *application) showMovie(w http.ResponseWriter, r *http.Request, id int64) {
+	v := validator.New()
+	fields := app.readCommaQuery(r.URL.Query(), "fields", []string{})
+	if data.ValidateFields(v, fields, data.MovieFieldSafeList); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
 
 	// Get movie from database
-	movie, err := app.models.Movie.Get(id)
+	movie, err := app.models.Movie.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrRecordNotFound) {
 			app.notFoundResponse(w, r)
@@ -105,7 +207,40 @@ func (app *application) showMovieHanlder(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := app.writeJSON(w, http.StatusOK, envelop{"movie": movie}, nil); err != nil {
+	if err := app.attachCertifications(r.Context(), movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// A movie rated above the limit of the user is reported as missing, as it is in the movies listing
+	if !app.ratingLimit(r).Allows(movie.Certifications) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.attachImages(r.Context(), movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.attachExternalIDs(r.Context(), movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.attachCollections(r.Context(), movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.localizeMovies(w, r, movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	selectedMovie, err := app.selectFields(movie, fields)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"movie": selectedMovie}, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
@@ -118,7 +253,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Get movie from database
-	movie, err := app.models.Movie.Get(id)
+	movie, err := app.models.Movie.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrRecordNotFound) {
 			app.notFoundResponse(w, r)
@@ -157,15 +292,21 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if movieInputData.Genres != nil {
 		movie.Genres = movieInputData.Genres
 	}
+	genres, err := app.genreIndex(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Validate user input
 	validator := validator.New()
-	if data.ValidateMovie(validator, movie); !validator.Valid() {
+	if data.ValidateMovie(validator, movie, genres); !validator.Valid() {
 		app.failValidationResponse(w, r, validator.Errors)
 		return
 	}
 
 	// Update movie to database
-	err = app.models.Movie.Update(movie)
+	err = app.models.Movie.Update(r.Context(), movie)
 	if err != nil {
 		if errors.Is(err, models.ErrEditConflict) {
 			app.editConflictResponse(w, r)
@@ -188,7 +329,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Get movie from database
-	_, err = app.models.Movie.Get(id)
+	movie, err := app.models.Movie.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrRecordNotFound) {
 			app.notFoundResponse(w, r)
@@ -197,15 +338,25 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	// The image records go with the movie, their files are removed once it is deleted
+	if err := app.attachImages(r.Context(), movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	err = app.models.Movie.Delete(id)
+	err = app.models.Movie.Delete(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrRecordNotFound) {
 			app.notFoundResponse(w, r)
 			return
 		}
 		app.serverErrorResponse(w, r, err)
+		return
 	}
+	for _, image := range movie.Images {
+		app.deleteStoredImage(r, image.StoredImage)
+	}
+
 	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "movie successfully deleted"}, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+
+	validator := validator.New()
+
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Page = app.readInt(qs, "page", 1, validator)
+	input.PageSize = app.readInt(qs, "page_size", 20, validator)
+	input.Sort = app.readString(qs, "sort", "id")
+	input.SortSafeList = []string{"id", "name", "-id", "-name"}
+
+	if data.ValidateFilters(validator, input.Filters); !validator.Valid() {
+		app.failValidationResponse(w, r, validator.Errors)
+		return
+	}
+	people, metadata, err := app.models.Person.GetAll(r.Context(), input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "people": people}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createPersonHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string `json:"name"`
+		Biography string `json:"biography"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	person := &data.Person{Name: input.Name, Biography: input.Biography}
+
+	validator := validator.New()
+	if data.ValidatePerson(validator, person); !validator.Valid() {
+		app.failValidationResponse(w, r, validator.Errors)
+		return
+	}
+
+	if err := app.models.Person.Create(r.Context(), person); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/people/%d", person.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"person": person}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showPersonHandler returns the person together with the movies they are credited on
+func (app *application) showPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.Person.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	credits, err := app.models.Credit.GetAllForPerson(r.Context(), person.ID, app.ratingLimit(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"person": person, "credits": credits}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.Person.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// We use pointers here for the input in order to support partial update
+	var input struct {
+		Name      *string `json:"name"`
+		Biography *string `json:"biography"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		person.Name = *input.Name
+	}
+	if input.Biography != nil {
+		person.Biography = *input.Biography
+	}
+
+	validator := validator.New()
+	if data.ValidatePerson(validator, person); !validator.Valid() {
+		app.failValidationResponse(w, r, validator.Errors)
+		return
+	}
+
+	if err := app.models.Person.Update(r.Context(), person); err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"person": person}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Person.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "person successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
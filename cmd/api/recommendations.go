@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+	if data.ValidateRecommendationLimit(v, limit); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Like showMovieHanlder, a movie rated above the limit of the user is reported as missing
+	if err := app.attachCertifications(r.Context(), movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !app.ratingLimit(r).Allows(movie.Certifications) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	similar, err := app.models.Recommendation.GetSimilar(r.Context(), id, limit, app.ratingLimit(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"similar": similar}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+	if data.ValidateRecommendationLimit(v, limit); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	recommendations, err := app.models.Recommendation.GetForUser(r.Context(), app.contextGetUser(r).ID, limit, app.ratingLimit(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"recommendations": recommendations}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+)
+
+// reloadableSettings are the settings applied again on SIGHUP, a change to any other setting needs a restart
+var reloadableSettings = []string{
+	"limiter-rps", "limiter-burst", "limiter-enabled",
+	"cors-trusted-origins",
+	"log-level",
+	"feature-signup", "feature-reviews", "feature-recommendations",
+}
+
+// reloadableConfig holds the settings which can change while serving. It is replaced as a whole on reload so
+// that a request never sees half of a new config.
+type reloadableConfig struct {
+	limiter  limiterConfig
+	cors     corsConfig
+	features featuresConfig
+}
+
+func (cfg config) reloadable() *reloadableConfig {
+	return &reloadableConfig{limiter: cfg.limiter, cors: cfg.cors, features: cfg.features}
+}
+
+// enabled reports whether the feature is switched on, an unknown feature is always off
+func (f featuresConfig) enabled(feature string) bool {
+	switch feature {
+	case "signup":
+		return f.signup
+	case "reviews":
+		return f.reviews
+	case "recommendations":
+		return f.recommendations
+	default:
+		return false
+	}
+}
+
+// reloadable returns the reloadable settings in effect
+func (app *application) reloadable() *reloadableConfig {
+	return app.dynamic.Load()
+}
+
+// handleReloads reloads the config on every SIGHUP until the server shuts down
+func (app *application) handleReloads() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-hup:
+			app.reloadConfig()
+		case <-app.shutdown:
+			return
+		}
+	}
+}
+
+// reloadConfig loads the config again, from the same file, environment and flags as on startup, and applies
+// the reloadable settings which changed. An invalid config is rejected as a whole. The .env file is not read
+// again, the environment of a running process can't change.
+func (app *application) reloadConfig() {
+	app.logger.Info("Reloading config")
+	cfg, _, entries, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		app.logger.Error("config reload failed, keeping the current config", "err", err.Error())
+		return
+	}
+
+	current := make(map[string]configEntry, len(app.configEntries))
+	for _, entry := range app.configEntries {
+		current[entry.name] = entry
+	}
+
+	// The raw values are compared, a change to a secret which redacts the same way is still a change
+	changed := 0
+	for i, entry := range entries {
+		old := current[entry.name]
+		if old.value == entry.value {
+			continue
+		}
+		if !slices.Contains(reloadableSettings, entry.name) {
+			app.logger.Warn("setting changed but requires a restart, the change is ignored", "setting", entry.name, "current", redact(entry.name, old.value), "new", redact(entry.name, entry.value))
+			// Kept as in effect, so that the warning is repeated until the restart
+			entries[i] = old
+			continue
+		}
+		app.logger.Info("setting changed", "setting", entry.name, "old", redact(entry.name, old.value), "new", redact(entry.name, entry.value), "source", entry.source)
+		changed++
+	}
+	if changed == 0 {
+		app.logger.Info("Config reloaded, no reloadable setting changed")
+		return
+	}
+
+	// The reloadable settings are swapped at once, the log level is the only one applied on its own
+	app.dynamic.Store(cfg.reloadable())
+	app.logLevel.Set(cfg.logLevel)
+	app.configEntries = entries
+	app.logger.Info("Config reloaded", "changed", changed)
+}
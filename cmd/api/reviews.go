@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+func (app *application) listMovieReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var filters data.Filters
+	v := validator.New()
+
+	qs := r.URL.Query()
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-updated_at")
+	filters.SortSafeList = []string{"updated_at", "rating", "-updated_at", "-rating"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if _, err := app.models.Movie.Get(r.Context(), id); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reviews, metadata, err := app.models.Review.GetAllForMovie(r.Context(), id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"metadata": metadata, "reviews": reviews}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createReviewHandler lets the current user rate a movie, a user can only review a movie once
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Rating int16  `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	review := &data.Review{MovieID: id, UserID: user.ID, Rating: input.Rating, Body: input.Body}
+
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Review.Create(r.Context(), review); err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateReview):
+			app.duplicateReviewResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelop{"review": review}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateReviewHandler replaces the rating and text of the current user review for the movie
+func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	review, err := app.models.Review.GetForUser(r.Context(), id, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Rating int16  `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review.Rating = input.Rating
+	review.Body = input.Body
+
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Review.Update(r.Context(), review); err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			app.editConflictResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"review": review}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if err := app.models.Review.Delete(r.Context(), id, user.ID); err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelop{"message": "review successfully deleted"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -13,22 +13,120 @@ func (app *application) routes() http.Handler {
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	// Map the routes
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthCheckHandler)
+	// Every route records its pattern for the metrics and the traces
+	handle := func(method, pattern string, handler http.HandlerFunc) {
+		router.HandlerFunc(method, pattern, app.matchedRoute(pattern, handler))
+	}
 
-	// Movies routes
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHanlder))
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	// Map the routes. The routes wrapped with requireDatabase are not available with the memory backend, the ones
+	// wrapped with requireFeature can be switched off by their feature-* setting.
+	handle(http.MethodGet, "/v1/healthcheck", app.healthCheckHandler)
+
+	// Movies routes, the movies can be read anonymously within the anonymous-max-rating limit
+	handle(http.MethodGet, "/v1/movies", app.requirePermissionUnlessAnonymous("movies:read", app.listMoviesHandler))
+	handle(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
+	// GET /v1/movies/lookup?imdb=tt0111161 is also served by showMovieHanlder
+	handle(http.MethodGet, "/v1/movies/:id", app.requirePermissionUnlessAnonymous("movies:read", app.showMovieHanlder))
+	handle(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
+	handle(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+
+	// Images routes, the images themselves are public so that they can be embedded anywhere
+	handle(http.MethodPost, "/v1/movies/:id/images", app.requirePermission("movies:write", app.uploadMovieImageHandler))
+	handle(http.MethodDelete, "/v1/movies/:id/images/:image_id", app.requirePermission("movies:write", app.deleteMovieImageHandler))
+	handle(http.MethodGet, "/v1/images/:key", app.serveImageHandler)
+
+	// Localizations routes
+	handle(http.MethodGet, "/v1/movies/:id/localizations", app.requirePermission("movies:read", app.listMovieLocalizationsHandler))
+	handle(http.MethodPut, "/v1/movies/:id/titles/:locale", app.requirePermission("movies:write", app.putMovieTitleHandler))
+	handle(http.MethodDelete, "/v1/movies/:id/titles/:locale", app.requirePermission("movies:write", app.deleteMovieTitleHandler))
+	handle(http.MethodPut, "/v1/movies/:id/release-dates/:country", app.requirePermission("movies:write", app.putMovieReleaseDateHandler))
+	handle(http.MethodDelete, "/v1/movies/:id/release-dates/:country", app.requirePermission("movies:write", app.deleteMovieReleaseDateHandler))
+
+	// Certifications routes
+	handle(http.MethodPut, "/v1/movies/:id/certifications/:country", app.requirePermission("movies:write", app.putMovieCertificationHandler))
+	handle(http.MethodDelete, "/v1/movies/:id/certifications/:country", app.requirePermission("movies:write", app.deleteMovieCertificationHandler))
+
+	// External IDs routes
+	handle(http.MethodPut, "/v1/movies/:id/external-ids/:source", app.requirePermission("movies:write", app.putMovieExternalIDHandler))
+	handle(http.MethodDelete, "/v1/movies/:id/external-ids/:source", app.requirePermission("movies:write", app.deleteMovieExternalIDHandler))
+
+	// Genres routes
+	handle(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	handle(http.MethodGet, "/v1/genres/:id", app.requirePermission("movies:read", app.showGenreHandler))
+	handle(http.MethodPost, "/v1/genres", app.requirePermission("genres:write", app.createGenreHandler))
+	handle(http.MethodPatch, "/v1/genres/:id", app.requirePermission("genres:write", app.updateGenreHandler))
+	handle(http.MethodDelete, "/v1/genres/:id", app.requirePermission("genres:write", app.deleteGenreHandler))
+
+	// Credits routes
+	handle(http.MethodGet, "/v1/movies/:id/credits", app.requireDatabase(app.requirePermission("movies:read", app.listMovieCreditsHandler)))
+	handle(http.MethodPost, "/v1/movies/:id/credits", app.requireDatabase(app.requirePermission("movies:write", app.createMovieCreditHandler)))
+	handle(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requireDatabase(app.requirePermission("movies:write", app.deleteMovieCreditHandler)))
+
+	// Reviews routes
+	handle(http.MethodGet, "/v1/movies/:id/reviews", app.requireDatabase(app.requirePermission("movies:read", app.listMovieReviewsHandler)))
+	handle(http.MethodPost, "/v1/movies/:id/reviews", app.requireDatabase(app.requireFeature("reviews", app.requireActivatedUser(app.createReviewHandler))))
+	handle(http.MethodPut, "/v1/movies/:id/reviews", app.requireDatabase(app.requireFeature("reviews", app.requireActivatedUser(app.updateReviewHandler))))
+	handle(http.MethodDelete, "/v1/movies/:id/reviews", app.requireDatabase(app.requireFeature("reviews", app.requireActivatedUser(app.deleteReviewHandler))))
+
+	// Watch history routes
+	handle(http.MethodPost, "/v1/movies/:id/watched", app.requireDatabase(app.requireActivatedUser(app.recordWatchHandler)))
+	handle(http.MethodGet, "/v1/users/me/history", app.requireDatabase(app.requireActivatedUser(app.listWatchHistoryHandler)))
+	handle(http.MethodGet, "/v1/users/me/stats", app.requireDatabase(app.requireActivatedUser(app.showWatchStatsHandler)))
+
+	// Recommendations routes
+	handle(http.MethodGet, "/v1/movies/:id/similar", app.requireDatabase(app.requireFeature("recommendations", app.requirePermission("movies:read", app.listSimilarMoviesHandler))))
+	handle(http.MethodGet, "/v1/users/me/recommendations", app.requireDatabase(app.requireFeature("recommendations", app.requirePermission("movies:read", app.listRecommendationsHandler))))
+
+	// People routes
+	handle(http.MethodGet, "/v1/people", app.requireDatabase(app.requirePermission("movies:read", app.listPeopleHandler)))
+	handle(http.MethodPost, "/v1/people", app.requireDatabase(app.requirePermission("movies:write", app.createPersonHandler)))
+	handle(http.MethodGet, "/v1/people/:id", app.requireDatabase(app.requirePermission("movies:read", app.showPersonHandler)))
+	handle(http.MethodPatch, "/v1/people/:id", app.requireDatabase(app.requirePermission("movies:write", app.updatePersonHandler)))
+	handle(http.MethodDelete, "/v1/people/:id", app.requireDatabase(app.requirePermission("movies:write", app.deletePersonHandler)))
 
 	// Users routes
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.createUserHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/users/activate", app.activateUserHandler)
+	handle(http.MethodPost, "/v1/users", app.requireFeature("signup", app.createUserHandler))
+	handle(http.MethodPut, "/v1/users/activate", app.activateUserHandler)
+	handle(http.MethodPut, "/v1/users/me/preferences", app.requireActivatedUser(app.updatePreferencesHandler))
+
+	// Watchlist routes
+	handle(http.MethodGet, "/v1/users/me/watchlist", app.requireDatabase(app.requireActivatedUser(app.showWatchlistHandler)))
+	handle(http.MethodPost, "/v1/users/me/watchlist", app.requireDatabase(app.requireActivatedUser(app.addWatchlistItemHandler)))
+	handle(http.MethodPatch, "/v1/users/me/watchlist/:movie_id", app.requireDatabase(app.requireActivatedUser(app.updateWatchlistItemHandler)))
+	handle(http.MethodDelete, "/v1/users/me/watchlist/:movie_id", app.requireDatabase(app.requireActivatedUser(app.removeWatchlistItemHandler)))
+
+	// Lists routes, public lists can be read without authentication
+	handle(http.MethodGet, "/v1/lists", app.requireDatabase(app.requireActivatedUser(app.listListsHandler)))
+	handle(http.MethodPost, "/v1/lists", app.requireDatabase(app.requireActivatedUser(app.createListHandler)))
+	handle(http.MethodGet, "/v1/lists/:id", app.requireDatabase(app.showListHandler))
+	handle(http.MethodPatch, "/v1/lists/:id", app.requireDatabase(app.requireActivatedUser(app.updateListHandler)))
+	handle(http.MethodDelete, "/v1/lists/:id", app.requireDatabase(app.requireActivatedUser(app.deleteListHandler)))
+	handle(http.MethodPost, "/v1/lists/:id/items", app.requireDatabase(app.requireActivatedUser(app.addListItemHandler)))
+	handle(http.MethodPatch, "/v1/lists/:id/items/:movie_id", app.requireDatabase(app.requireActivatedUser(app.updateListItemHandler)))
+	handle(http.MethodDelete, "/v1/lists/:id/items/:movie_id", app.requireDatabase(app.requireActivatedUser(app.removeListItemHandler)))
+
+	// Collections routes
+	handle(http.MethodGet, "/v1/collections", app.requirePermission("movies:read", app.listCollectionsHandler))
+	handle(http.MethodPost, "/v1/collections", app.requirePermission("movies:write", app.createCollectionHandler))
+	handle(http.MethodGet, "/v1/collections/:id", app.requirePermission("movies:read", app.showCollectionHandler))
+	handle(http.MethodPatch, "/v1/collections/:id", app.requirePermission("movies:write", app.updateCollectionHandler))
+	handle(http.MethodDelete, "/v1/collections/:id", app.requirePermission("movies:write", app.deleteCollectionHandler))
+	handle(http.MethodPut, "/v1/collections/:id/artwork", app.requirePermission("movies:write", app.putCollectionArtworkHandler))
+	handle(http.MethodDelete, "/v1/collections/:id/artwork", app.requirePermission("movies:write", app.deleteCollectionArtworkHandler))
+	handle(http.MethodPost, "/v1/collections/:id/movies", app.requirePermission("movies:write", app.addCollectionMovieHandler))
+	handle(http.MethodPatch, "/v1/collections/:id/movies/:movie_id", app.requirePermission("movies:write", app.updateCollectionMovieHandler))
+	handle(http.MethodDelete, "/v1/collections/:id/movies/:movie_id", app.requirePermission("movies:write", app.removeCollectionMovieHandler))
+
+	// Admin routes
+	handle(http.MethodGet, "/v1/admin/movies/duplicates", app.requirePermission("movies:admin", app.listDuplicateMoviesHandler))
+	handle(http.MethodPost, "/v1/admin/movies/:id/merge", app.requirePermission("movies:admin", app.mergeMovieHandler))
 
 	// Tokens routes
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationToken)
+	handle(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationToken)
 
-	return app.recoverPanic(app.enableCORS(app.rateLimitMiddleware(app.authenticate(router))))
+	// Each middleware after recoverPanic has a span of its own, within the span of the request
+	chain := app.traced("middleware authenticate", app.authenticate(router))
+	chain = app.traced("middleware rateLimit", app.rateLimitMiddleware(chain))
+	chain = app.traced("middleware enableCORS", app.enableCORS(chain))
+	return app.trackRequest(app.traceRequest(app.logRequest(app.recordMetrics(app.recoverPanic(chain)))))
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+)
+
+// staleMovies is a [models.MovieRepository] whose movies are edited by someone else right after they are read,
+// so that the update of the handler always loses the race
+type staleMovies struct {
+	models.MovieRepository
+}
+
+func (m staleMovies) Get(ctx context.Context, id int64) (*data.Movie, error) {
+	movie, err := m.MovieRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	concurrent := *movie
+	if err := m.MovieRepository.Update(ctx, &concurrent); err != nil {
+		return nil, err
+	}
+	return movie, nil
+}
+
+func TestHandlers(t *testing.T) {
+	app := newTestApplication(t)
+	writer := newTestUser(t, app, "writer@example.com", time.Hour, "movies:read", "movies:write")
+	expired := newTestUser(t, app, "expired@example.com", -time.Hour, "movies:read")
+	if err := app.models.Movie.Create(context.Background(), &data.Movie{Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama"}}); err != nil {
+		t.Fatal(err)
+	}
+	handler := app.routes()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+		wantBody   string // A fragment of the response body
+	}{
+		{"healthcheck", http.MethodGet, "/v1/healthcheck", "", "", http.StatusOK, `"status"`},
+		{"anonymous list", http.MethodGet, "/v1/movies", "", "", http.StatusOK, `"Casablanca"`},
+		{"anonymous show", http.MethodGet, "/v1/movies/1", "", "", http.StatusOK, `"Casablanca"`},
+		{"missing movie", http.MethodGet, "/v1/movies/404", "", "", http.StatusNotFound, `"request_id"`},
+		{"anonymous create", http.MethodPost, "/v1/movies", `{"title":"Vertigo","year":1958,"runtime":"128 mins","genres":["thriller"]}`, "", http.StatusUnauthorized, `"error"`},
+		{"expired token", http.MethodGet, "/v1/movies", "", expired.Plain, http.StatusUnauthorized, `"invalid or missing authentication header"`},
+		{"unknown token", http.MethodGet, "/v1/movies", "", strings.Repeat("A", 26), http.StatusUnauthorized, `"invalid or missing authentication header"`},
+		{"create", http.MethodPost, "/v1/movies", `{"title":"Vertigo","year":1958,"runtime":"128 mins","genres":["thriller"]}`, writer.Plain, http.StatusCreated, `"version": 1`},
+		{"invalid movie", http.MethodPost, "/v1/movies", `{"title":"","year":1958,"runtime":"128 mins","genres":["thriller"]}`, writer.Plain, http.StatusUnprocessableEntity, `"title"`},
+		{"update", http.MethodPatch, "/v1/movies/1", `{"year":1943}`, writer.Plain, http.StatusCreated, `"version": 2`},
+		{"duplicate email", http.MethodPost, "/v1/users", `{"name":"Other","email":"WRITER@example.com","password":"pa55word1234"}`, "", http.StatusUnprocessableEntity, `"a user with this email address already existed"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := serve(handler, tt.method, tt.path, tt.body, tt.token)
+			if status != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", status, tt.wantStatus, body)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body %s does not contain %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("edit conflict", func(t *testing.T) {
+		app.models.Movie = staleMovies{app.models.Movie}
+		status, body := serve(app.routes(), http.MethodPatch, "/v1/movies/1", `{"year":1944}`, writer.Plain)
+		if status != http.StatusConflict {
+			t.Errorf("got status %d, want %d: %s", status, http.StatusConflict, body)
+		}
+		var response struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(body), &response); err != nil || response.Error == "" {
+			t.Errorf("got body %s, want an error message", body)
+		}
+	})
+}
+
+// TestCatalogHandlers runs the catalog routes in order on the in-memory backend, every step builds on the
+// previous ones
+func TestCatalogHandlers(t *testing.T) {
+	app := newTestApplication(t)
+	writer := newTestUser(t, app, "writer@example.com", time.Hour, "movies:read", "movies:write")
+	if err := app.models.Movie.Create(context.Background(), &data.Movie{Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama"}}); err != nil {
+		t.Fatal(err)
+	}
+	handler := app.routes()
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string // A fragment of the response body
+	}{
+		{"list genres", http.MethodGet, "/v1/genres", "", http.StatusOK, `"science-fiction"`},
+		{"put external id", http.MethodPut, "/v1/movies/1/external-ids/imdb", `{"id":"tt0034583"}`, http.StatusOK, ``},
+		{"lookup", http.MethodGet, "/v1/movies/lookup?imdb=tt0034583", "", http.StatusOK, `"Casablanca"`},
+		{"put certification", http.MethodPut, "/v1/movies/1/certifications/us", `{"certification":"pg"}`, http.StatusOK, ``},
+		{"put title", http.MethodPut, "/v1/movies/1/titles/fr", `{"title":"Casablanca (FR)"}`, http.StatusOK, ``},
+		{"create collection", http.MethodPost, "/v1/collections", `{"name":"Classics"}`, http.StatusCreated, `"Classics"`},
+		{"add collection movie", http.MethodPost, "/v1/collections/1/movies", `{"movie_id":1}`, http.StatusCreated, `"position": 1`},
+		{"list by collection", http.MethodGet, "/v1/movies?collection_id=1", "", http.StatusOK, `"Casablanca"`},
+		{"show", http.MethodGet, "/v1/movies/1?lang=fr", "", http.StatusOK, `"Casablanca (FR)"`},
+		{"show collection", http.MethodGet, "/v1/collections/1", "", http.StatusOK, `"movie_title": "Casablanca"`},
+	}
+
+	for _, step := range steps {
+		status, body := serve(handler, step.method, step.path, step.body, writer.Plain)
+		if status != step.wantStatus {
+			t.Fatalf("%s: got status %d, want %d: %s", step.name, status, step.wantStatus, body)
+		}
+		if !strings.Contains(body, step.wantBody) {
+			t.Errorf("%s: body %s does not contain %s", step.name, body, step.wantBody)
+		}
+	}
+
+	// The movie shows its catalog data
+	_, body := serve(handler, http.MethodGet, "/v1/movies/1", "", writer.Plain)
+	for _, want := range []string{`"tt0034583"`, `"PG"`, `"Classics"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body %s does not contain %s", body, want)
+		}
+	}
+}
+
+func TestServerErrorResponseOnInvalidSort(t *testing.T) {
+	app := newTestApplication(t)
+	w := httptest.NewRecorder()
+	app.serverErrorResponse(w, httptest.NewRequest(http.MethodGet, "/v1/movies", nil), fmt.Errorf("%w: year", data.ErrInvalidSort))
+
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"sort"`) {
+		t.Errorf("got %d %s, want 422 with a sort error", w.Code, w.Body.String())
+	}
+}
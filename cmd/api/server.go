@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,7 +13,22 @@ import (
 	"time"
 )
 
+// errShuttingDown is the cause of the cancellation of the requests still running when the server stops
+var errShuttingDown = errors.New("server shutting down")
+
+const (
+	// shutdownTimeout is the window given to the servers to stop gracefully
+	shutdownTimeout = 5 * time.Second
+	// requestDrainPeriod is left to the running requests to complete, then their database work is canceled
+	// so that they can answer with a 503 in the rest of the shutdown timeout
+	requestDrainPeriod = 4 * time.Second
+)
+
 func (app *application) serve() error {
+	// The requests outliving the drain period are canceled so that their database work stops too
+	baseCtx, cancelBase := context.WithCancelCause(context.Background())
+	defer cancelBase(errShuttingDown)
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.port),
 		Handler:      app.routes(),
@@ -20,9 +36,24 @@ func (app *application) serve() error {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		ErrorLog:     slog.NewLogLogger(slog.NewTextHandler(os.Stderr, nil), slog.LevelError),
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
 	shutdownError := make(chan error)
 
+	// The metrics are served on their own listener, meant to be reachable by the scraper only
+	var adminSrv *http.Server
+	if app.config.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", app.metrics.registry.Handler())
+		adminSrv = &http.Server{
+			Addr:         app.config.metricsAddr,
+			Handler:      mux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			ErrorLog:     slog.NewLogLogger(slog.NewTextHandler(os.Stderr, nil), slog.LevelError),
+		}
+	}
+
 	// Graceful shutdown
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -32,11 +63,20 @@ func (app *application) serve() error {
 		s := <-quit
 
 		app.logger.Info("Shutting down server", "signal", s.String())
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
+		// Stop the scheduled jobs from starting new runs
+		close(app.shutdown)
+
+		if adminSrv != nil {
+			if err := adminSrv.Shutdown(ctx); err != nil {
+				app.logger.Error("error shutting down the metrics server", "err", err.Error())
+			}
+		}
+
 		// Call shutdown with 5s timeout context so that the server has a 5 second window to clean up
-		err := srv.Shutdown(ctx)
+		err := shutdownServer(ctx, srv, cancelBase, requestDrainPeriod)
 		if err != nil {
 			shutdownError <- err
 		}
@@ -52,7 +92,24 @@ func (app *application) serve() error {
 		shutdownError <- nil
 	}()
 
-	app.logger.Info("Starting server", "Address", srv.Addr, "environment", app.config.env, "limiter", app.config.limiter)
+	if adminSrv != nil {
+		// Bound before serving so that an unusable address fails the startup
+		adminListener, err := net.Listen("tcp", adminSrv.Addr)
+		if err != nil {
+			return fmt.Errorf("metrics listener: %w", err)
+		}
+		go func() {
+			if err := adminSrv.Serve(adminListener); !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("metrics server stopped", "err", err.Error())
+			}
+		}()
+		app.logger.Info("Serving metrics", "Address", adminSrv.Addr)
+	}
+
+	app.scheduleJobs()
+	go app.handleReloads()
+
+	app.logger.Info("Starting server", "Address", srv.Addr, "environment", app.config.env, "limiter", app.config.limiter, "log_level", app.config.logLevel)
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
@@ -65,3 +122,11 @@ func (app *application) serve() error {
 	app.logger.Info("Stopped server", "Address", srv.Addr, "environment", app.config.env)
 	return nil
 }
+
+// shutdownServer stops srv gracefully. The requests still running after drainPeriod have their context
+// canceled with errShuttingDown, their database work stops and they answer with a 503 before ctx is done.
+func shutdownServer(ctx context.Context, srv *http.Server, cancelRequests context.CancelCauseFunc, drainPeriod time.Duration) error {
+	drained := time.AfterFunc(drainPeriod, func() { cancelRequests(errShuttingDown) })
+	defer drained.Stop()
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownCancelsRunningRequests(t *testing.T) {
+	app := newTestApplication(t)
+	started := make(chan struct{})
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// Stands for a slow query, the models report the cancellation of the request context
+		<-r.Context().Done()
+		_, err := app.models.Movie.Get(r.Context(), 1)
+		app.serverErrorResponse(w, r, err)
+	}))
+	baseCtx, cancelBase := context.WithCancelCause(context.Background())
+	defer cancelBase(nil)
+	ts.Config.BaseContext = func(net.Listener) context.Context { return baseCtx }
+	ts.Start()
+	defer ts.Close()
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		res, err := http.Get(ts.URL)
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		responses <- response{status: res.StatusCode, body: string(body), err: err}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownServer(ctx, ts.Config, cancelBase, 10*time.Millisecond); err != nil {
+		t.Fatalf("shutdown error = %v, want the request to complete", err)
+	}
+
+	res := <-responses
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.status != http.StatusServiceUnavailable || !strings.Contains(res.body, "the server is shutting down") {
+		t.Errorf("got %d %s, want 503 telling the server is shutting down", res.status, res.body)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/tracing"
+)
+
+// newTestApplication returns an application on the in-memory backend, with every feature enabled
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+	app := &application{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		models:   models.NewMemory(),
+		metrics:  newAppMetrics(),
+		tracer:   tracing.NewTracer(nil),
+		shutdown: make(chan struct{}),
+	}
+	app.config.db.backend = backendMemory
+	app.config.features = featuresConfig{signup: true, reviews: true, recommendations: true}
+	app.dynamic.Store(app.config.reloadable())
+	t.Cleanup(app.wg.Wait)
+	return app
+}
+
+// newTestUser stores an activated user with the permissions and returns an authentication token valid for ttl
+func newTestUser(t *testing.T, app *application, email string, ttl time.Duration, permissions ...string) *data.Token {
+	t.Helper()
+	ctx := context.Background()
+	user := &data.User{Name: "Test", Email: email, Password: data.Password{Hash: []byte("hash")}, Activated: true}
+	if err := app.models.User.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) > 0 {
+		if err := app.models.Permission.AddForUser(ctx, user.ID, permissions...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, err := app.models.Token.New(ctx, user.ID, ttl, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// serve runs the request through the handler and returns the status and body of the response
+func serve(handler http.Handler, method, path, body, token string) (int, string) {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
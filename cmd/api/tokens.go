@@ -31,7 +31,7 @@ func (app *application) createAuthenticationToken(w http.ResponseWriter, r *http
 		return
 	}
 
-	user, err := app.models.User.GetByEmail(input.Email)
+	user, err := app.models.User.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, models.ErrRecordNotFound):
@@ -55,7 +55,7 @@ func (app *application) createAuthenticationToken(w http.ResponseWriter, r *http
 	}
 
 	// Create new authentication token with 24hour expiry
-	authenticationToken, err := app.models.Token.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	authenticationToken, err := app.models.Token.New(r.Context(), user.ID, 24*time.Hour, data.ScopeAuthentication)
 	if err != nil {
 		err = fmt.Errorf("error calling Token.New in createAuthenticationToken %w", err)
 		app.serverErrorResponse(w, r, err)
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/tracing"
+)
+
+// serviceName identifies the API in the exported traces
+const serviceName = "greenlight-api"
+
+// The exporters of the tracing-exporter setting
+const (
+	tracingExporterNone     = "none"
+	tracingExporterStdout   = "stdout"
+	tracingExporterOTLPFile = "otlp-file"
+)
+
+// newTracer returns the tracer of the exporter picked by the tracing-exporter setting. The spans are recorded
+// with every exporter, so that the logs of a request carry its trace ID even when nothing is exported.
+func newTracer(cfg config) (*tracing.Tracer, error) {
+	switch cfg.tracing.exporter {
+	case tracingExporterStdout:
+		return tracing.NewTracer(tracing.NewStdoutExporter(nil)), nil
+	case tracingExporterOTLPFile:
+		exporter, err := tracing.NewOTLPFileExporter(cfg.tracing.file, serviceName)
+		if err != nil {
+			return nil, err
+		}
+		return tracing.NewTracer(exporter), nil
+	default:
+		return tracing.NewTracer(nil), nil
+	}
+}
+
+// traceRequest middleware records the request in a server span, the parent of the spans of the middleware,
+// the handler, the queries and the emails. The trace of a W3C traceparent header is continued.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, err := tracing.ParseTraceparent(r.Header.Get("traceparent")); err == nil {
+			ctx = tracing.ContextWithRemoteSpanContext(ctx, parent)
+		}
+		ctx, span := app.tracer.Start(ctx, r.Method, tracing.SpanKindServer,
+			tracing.Attr("http.request.method", r.Method),
+			tracing.Attr("url.path", r.URL.Path),
+			tracing.Attr("client.address", r.RemoteAddr),
+		)
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		info := app.contextGetRequestInfo(r)
+		// The span is named after the route rather than the path, which holds IDs
+		if info.route != "" {
+			span.SetName(r.Method + " " + info.route)
+			span.SetAttributes(tracing.Attr("http.route", info.route))
+		}
+		span.SetAttributes(tracing.Attr("http.response.status_code", info.response.status))
+		// The client errors are the client's, only the server errors fail the span
+		if info.response.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%d %s", info.response.status, http.StatusText(info.response.status)))
+		}
+	})
+}
+
+// traced records the middleware in a span of its own, which includes the rest of the chain
+func (app *application) traced(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), name, tracing.SpanKindInternal)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
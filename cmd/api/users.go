@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -37,8 +38,27 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Add user to db
-	err = app.models.User.Create(user)
+	// The user, its permission and its activation token are created together, a failure leaves none of them
+	var token *data.Token
+	err = app.models.WithTx(r.Context(), func(tx models.Models) error {
+		// Add user to db
+		if err := tx.User.Create(r.Context(), user); err != nil {
+			return err
+		}
+
+		// Add the movies:read permission to the newly created user
+		if err := tx.Permission.AddForUser(r.Context(), user.ID, "movies:read"); err != nil {
+			return fmt.Errorf("error Permission.AddForUser %w", err)
+		}
+
+		// Create a activation token to be sent to user welcome email
+		var err error
+		token, err = tx.Token.New(r.Context(), user.ID, (24 * 3 * time.Hour), data.ScopeActivation)
+		if err != nil {
+			return fmt.Errorf("error create token %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			validator.AddError("email", "a user with this email address already existed")
@@ -48,35 +68,20 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-
-	// Add the movies:read permission to the newly created user
-	err = app.models.Permission.AddForUser(user.ID, "movies:read")
-	if err != nil {
-		err = fmt.Errorf("error Permission.AddForUser %w", err)
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	// Create a activation token to be sent to user welcome email
-	token, err := app.models.Token.New(user.ID, (24 * 3 * time.Hour), data.ScopeActivation)
-	// TODO: if there is an error when creating token, should rollback the user creation as well, or has another endpoint to re-trigger the token creation
-	if err != nil {
-		err = fmt.Errorf("error create token %w", err)
-		app.serverErrorResponse(w, r, err)
-		return
-	}
 	// Send welcome email to user in the background with activation token
-	app.logger.Info("Create user and activation token successfully, sending welcome email!")
+	app.logger.InfoContext(r.Context(), "Create user and activation token successfully, sending welcome email!")
+	// The email is sent after the response, its span stays in the trace of the request
+	ctx := context.WithoutCancel(r.Context())
 	app.background(func() {
 		data := map[string]any{
 			"activationToken": token.Plain,
 			"userId":          user.ID,
 		}
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
-		if err != nil {
-			app.logger.Error("Error sending user to", "user: email", user.Email)
+		if err := app.sendEmail(ctx, user.Email, "user_welcome.tmpl", data); err != nil {
+			app.logger.ErrorContext(ctx, "Error sending user to", "user: email", user.Email, "err", err.Error())
+			return
 		}
-		app.logger.Info("Email sent successfully for:", "user email", user.Email)
+		app.logger.InfoContext(ctx, "Email sent successfully for:", "user email", user.Email)
 	})
 
 	err = app.writeJSON(w, http.StatusCreated, envelop{"user": user}, nil)
@@ -102,21 +107,63 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := app.models.User.GetUserWithToken(userInput.PlainToken, data.ScopeActivation)
+	// The user is activated and its activation tokens are deleted together, so that a token can't be left
+	// usable for an activated user
+	var user *data.User
+	err = app.models.WithTx(r.Context(), func(tx models.Models) error {
+		var err error
+		user, err = tx.User.GetUserWithToken(r.Context(), userInput.PlainToken, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
+		// Update the user in db activate
+		user.Activated = true
+		if err := tx.User.Update(r.Context(), user); err != nil {
+			return err
+		}
+		app.logger.InfoContext(r.Context(), "Update user successfully, delete all activation token for user", "email", user.Email)
+		return tx.User.DeleteAllTokenForUser(r.Context(), data.ScopeActivation, user.ID)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, models.ErrRecordNotFound):
 			v.AddError("token", "invalid token or expired token")
 			app.failValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
-	// Update the user in db activate
-	user.Activated = true
-	err = app.models.User.Update(user)
-	if err != nil {
+	app.logger.InfoContext(r.Context(), "Activated user successfully", "email", user.Email)
+	if err := app.writeJSON(w, http.StatusOK, envelop{"user": user}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updatePreferencesHandler replaces the preferences of the authenticated user. A null max_rating removes the limit.
+func (app *application) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MaxRating *data.Certification `json:"max_rating"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if input.MaxRating != nil {
+		if data.ValidateCertification(v, input.MaxRating); !v.Valid() {
+			app.failValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	user := app.contextGetUser(r)
+	user.MaxRating = input.MaxRating
+	if err := app.models.User.Update(r.Context(), user); err != nil {
 		if errors.Is(err, models.ErrEditConflict) {
 			app.editConflictResponse(w, r)
 			return
@@ -124,14 +171,8 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	app.logger.Info("Update user successfully, delete all activation token for user", "email", user.Email)
-	if err := app.models.User.DeleteAllTokenForUser(data.ScopeActivation, user.ID); err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-	app.logger.Info("Activated user successfully", "email", user.Email)
+
 	if err := app.writeJSON(w, http.StatusOK, envelop{"user": user}, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
-		return
 	}
 }
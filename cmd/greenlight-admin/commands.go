@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+	"golang.org/x/term"
+)
+
+type userResult struct {
+	ID              int64              `json:"id"`
+	Name            string             `json:"name"`
+	Email           string             `json:"email"`
+	Activated       bool               `json:"activated"`
+	Permissions     models.Permissions `json:"permissions"`
+	ActivationToken string             `json:"activation_token,omitempty"` // Only set when a user is created inactive
+}
+
+func (r userResult) table() ([]string, [][]string) {
+	row := []string{strconv.FormatInt(r.ID, 10), r.Name, r.Email, strconv.FormatBool(r.Activated), strings.Join(r.Permissions, ",")}
+	if r.ActivationToken == "" {
+		return []string{"ID", "NAME", "EMAIL", "ACTIVATED", "PERMISSIONS"}, [][]string{row}
+	}
+	return []string{"ID", "NAME", "EMAIL", "ACTIVATED", "PERMISSIONS", "ACTIVATION TOKEN"}, [][]string{append(row, r.ActivationToken)}
+}
+
+type permissionsResult struct {
+	Email       string             `json:"email"`
+	Permissions models.Permissions `json:"permissions"`
+}
+
+func (r permissionsResult) table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(r.Permissions))
+	for _, code := range r.Permissions {
+		rows = append(rows, []string{r.Email, code})
+	}
+	return []string{"EMAIL", "PERMISSION"}, rows
+}
+
+type tokensResult struct {
+	Email   string `json:"email,omitempty"` // Empty when the tokens of every user are purged
+	Deleted int64  `json:"deleted"`
+}
+
+func (r tokensResult) table() ([]string, [][]string) {
+	if r.Email == "" {
+		return []string{"DELETED"}, [][]string{{strconv.FormatInt(r.Deleted, 10)}}
+	}
+	return []string{"EMAIL", "DELETED"}, [][]string{{r.Email, strconv.FormatInt(r.Deleted, 10)}}
+}
+
+// getUser returns the user with the email, a missing user is reported with its email
+func getUser(ctx context.Context, m models.Models, email string) (*data.User, error) {
+	user, err := m.User.GetByEmail(ctx, email)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, fmt.Errorf("no user with email %s: %w", email, err)
+	}
+	return user, err
+}
+
+// validationError reports every error of the validator in a single error
+func validationError(v *validator.Validator) error {
+	fields := make([]string, 0, len(v.Errors))
+	for field, message := range v.Errors {
+		fields = append(fields, field+": "+message)
+	}
+	slices.Sort(fields)
+	return errors.New(strings.Join(fields, ", "))
+}
+
+// readPassword prompts for the password without echoing it when r is a terminal, otherwise it reads the
+// first line of r. It is never an argument, which would leave it in the shell history and the process list.
+func readPassword(r io.Reader) (string, error) {
+	if f, ok := r.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func createUser(ctx context.Context, admin *admin, args []string) (result, error) {
+	flags := flag.NewFlagSet("users create", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	name := flags.String("name", "", "Name of the user")
+	email := flags.String("email", "", "Email of the user")
+	activated := flags.Bool("activated", false, "Create the user activated rather than issuing an activation token")
+	permissions := flags.String("permissions", "movies:read", "Comma separated permissions granted to the user")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if flags.NArg() != 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", errUsage, flags.Arg(0))
+	}
+
+	password, err := readPassword(admin.stdin)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the password: %w", err)
+	}
+	user := &data.User{Name: *name, Email: *email, Activated: *activated}
+	if err := user.Password.Set(password); err != nil {
+		return nil, err
+	}
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.Valid() {
+		return nil, validationError(v)
+	}
+
+	codes := splitCodes(*permissions)
+	res := userResult{}
+	// As with the signup, nothing is created when any step fails
+	err = admin.models.WithTx(ctx, func(tx models.Models) error {
+		if err := tx.User.Create(ctx, user); err != nil {
+			return err
+		}
+		granted, err := grant(ctx, tx, user.ID, codes)
+		if err != nil {
+			return err
+		}
+		res = userResult{ID: user.ID, Name: user.Name, Email: user.Email, Activated: user.Activated, Permissions: granted}
+
+		if !user.Activated {
+			token, err := tx.Token.New(ctx, user.ID, 24*3*time.Hour, data.ScopeActivation)
+			if err != nil {
+				return err
+			}
+			res.ActivationToken = token.Plain
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	admin.logger.Info("user created", "id", user.ID, "email", user.Email)
+	return res, nil
+}
+
+func activateUser(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%w: expected EMAIL", errUsage)
+	}
+
+	var res userResult
+	err := admin.models.WithTx(ctx, func(tx models.Models) error {
+		user, err := getUser(ctx, tx, args[0])
+		if err != nil {
+			return err
+		}
+		if !user.Activated {
+			user.Activated = true
+			if err := tx.User.Update(ctx, user); err != nil {
+				return err
+			}
+		}
+		if err := tx.User.DeleteAllTokenForUser(ctx, data.ScopeActivation, user.ID); err != nil {
+			return err
+		}
+		permissions, err := tx.Permission.GetAllForUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		res = userResult{ID: user.ID, Name: user.Name, Email: user.Email, Activated: user.Activated, Permissions: permissions}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// splitCodes splits a comma separated list of permission codes, blank codes are dropped
+func splitCodes(s string) []string {
+	var codes []string
+	for _, code := range strings.Split(s, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// grant grants the codes the user doesn't have yet and returns all of its permissions. Granting a code
+// which doesn't exist is silently ignored by the database, it is reported as an error instead.
+func grant(ctx context.Context, m models.Models, userID int64, codes []string) (models.Permissions, error) {
+	current, err := m.Permission.GetAllForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	missing := slices.DeleteFunc(slices.Clone(codes), current.Includes)
+	if len(missing) == 0 {
+		return current, nil
+	}
+
+	if err := m.Permission.AddForUser(ctx, userID, missing...); err != nil {
+		return nil, err
+	}
+	granted, err := m.Permission.GetAllForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if unknown := slices.DeleteFunc(missing, granted.Includes); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown permissions: %s", strings.Join(unknown, ", "))
+	}
+	return granted, nil
+}
+
+func listPermissions(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%w: expected EMAIL", errUsage)
+	}
+	user, err := getUser(ctx, admin.models, args[0])
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := admin.models.Permission.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return permissionsResult{Email: user.Email, Permissions: permissions}, nil
+}
+
+func grantPermissions(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("%w: expected EMAIL CODE...", errUsage)
+	}
+
+	var res permissionsResult
+	err := admin.models.WithTx(ctx, func(tx models.Models) error {
+		user, err := getUser(ctx, tx, args[0])
+		if err != nil {
+			return err
+		}
+		permissions, err := grant(ctx, tx, user.ID, args[1:])
+		if err != nil {
+			return err
+		}
+		res = permissionsResult{Email: user.Email, Permissions: permissions}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func revokePermissions(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("%w: expected EMAIL CODE...", errUsage)
+	}
+
+	var res permissionsResult
+	err := admin.models.WithTx(ctx, func(tx models.Models) error {
+		user, err := getUser(ctx, tx, args[0])
+		if err != nil {
+			return err
+		}
+		if err := tx.Permission.RemoveForUser(ctx, user.ID, args[1:]...); err != nil {
+			return err
+		}
+		permissions, err := tx.Permission.GetAllForUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		res = permissionsResult{Email: user.Email, Permissions: permissions}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func revokeTokens(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%w: expected EMAIL", errUsage)
+	}
+	user, err := getUser(ctx, admin.models, args[0])
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := admin.models.Token.DeleteAllForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return tokensResult{Email: user.Email, Deleted: deleted}, nil
+}
+
+func purgeTokens(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", errUsage, args[0])
+	}
+	deleted, err := admin.models.Token.DeleteExpired(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tokensResult{Deleted: deleted}, nil
+}
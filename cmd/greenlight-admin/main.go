@@ -0,0 +1,158 @@
+// Command greenlight-admin runs the routine operations on the users, permissions, tokens and movies of the
+// database, through the same models as the API.
+//
+//	go run ./cmd/greenlight-admin -db-dsn=postgres://greenlight@localhost/greenlight users create -name Alice -email alice@example.com
+//	go run ./cmd/greenlight-admin -output=json permissions grant alice@example.com movies:write
+//
+// The DSN is resolved like the API does, from the config file and the GREENLIGHT_* environment, unless
+// -db-dsn is given. Every command prints its result as a table, or as JSON with -output=json.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/settings"
+)
+
+const usage = `Usage: %s [flags] COMMAND [args]
+
+Commands:
+  users create -name NAME -email EMAIL [-activated] [-permissions CODES]
+                                     create a user, the password is prompted for on the
+                                     terminal or read from the first line of stdin
+  users activate EMAIL               activate a user and delete its activation tokens
+  permissions list EMAIL             list the permissions of a user
+  permissions grant EMAIL CODE...    grant permissions to a user
+  permissions revoke EMAIL CODE...   revoke permissions from a user
+  tokens revoke EMAIL                delete every token of a user, which signs the user out
+  tokens purge                       delete the expired tokens of every user
+  movies seed                        create the sample movies which don't exist yet
+
+Flags:
+`
+
+// result is the outcome of a command, it is printed as JSON or as the table it returns
+type result interface {
+	table() (header []string, rows [][]string)
+}
+
+// command runs a subcommand with its arguments, the ones following its name
+type command func(ctx context.Context, admin *admin, args []string) (result, error)
+
+var commands = map[string]command{
+	"users create":       createUser,
+	"users activate":     activateUser,
+	"permissions list":   listPermissions,
+	"permissions grant":  grantPermissions,
+	"permissions revoke": revokePermissions,
+	"tokens revoke":      revokeTokens,
+	"tokens purge":       purgeTokens,
+	"movies seed":        seedMovies,
+}
+
+type admin struct {
+	logger *slog.Logger
+	models models.Models
+	stdin  io.Reader
+}
+
+// errUsage is returned by a command called with invalid arguments, the usage is printed
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	var configFile, dsn, output string
+	flag.StringVar(&configFile, "config", "", "YAML or TOML config file of the API, see also "+settings.EnvPrefix+"CONFIG")
+	flag.StringVar(&dsn, "db-dsn", "", "PostgreSQL DSN (default the db-dsn setting of the config file and the environment)")
+	flag.StringVar(&output, "output", "table", "Output format (table|json)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := slog.Default()
+	// The .env file is optional, its variables are read like the rest of the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("Error loading .env file", "err", err.Error())
+		os.Exit(1)
+	}
+	if dsn == "" {
+		var err error
+		if dsn, err = settings.DSN(configFile, os.Getenv); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if output != "table" && output != "json" {
+		logger.Error("invalid output, must be table or json", "output", output)
+		os.Exit(2)
+	}
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0) + " " + flag.Arg(1)
+	run, ok := commands[name]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Interrupting the command cancels the query in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		logger.Error("error opening database connection", "err", err.Error())
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	admin := &admin{logger: logger, models: models.New(models.NewDB(pool, models.DefaultQueryTimeout)), stdin: os.Stdin}
+	res, err := run(ctx, admin, flag.Args()[2:])
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n\n", name, err)
+			flag.Usage()
+			os.Exit(2)
+		}
+		logger.Error(name+" failed", "err", err.Error())
+		os.Exit(1)
+	}
+
+	if err := write(os.Stdout, output, res); err != nil {
+		logger.Error("error writing the result", "err", err.Error())
+		os.Exit(1)
+	}
+}
+
+// write prints the result in the output format
+func write(w io.Writer, output string, res result) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(res)
+	}
+
+	header, rows := res.table()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// sampleMovies are the movies created by the seed command, their genres are names of [data.SeedGenres]
+var sampleMovies = []data.Movie{
+	{Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama", "romance", "war"}},
+	{Title: "The Shawshank Redemption", Year: 1994, Runtime: 142, Genres: []string{"drama", "crime"}},
+	{Title: "Spirited Away", Year: 2001, Runtime: 125, Genres: []string{"animation", "fantasy", "family"}},
+	{Title: "The Matrix", Year: 1999, Runtime: 136, Genres: []string{"action", "science-fiction"}},
+	{Title: "Black Panther", Year: 2018, Runtime: 134, Genres: []string{"action", "adventure", "science-fiction"}},
+	{Title: "Parasite", Year: 2019, Runtime: 132, Genres: []string{"comedy", "thriller", "drama"}},
+	{Title: "Deadpool", Year: 2016, Runtime: 108, Genres: []string{"action", "comedy"}},
+	{Title: "The Breakfast Club", Year: 1985, Runtime: 97, Genres: []string{"comedy", "drama"}},
+	{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation", "adventure"}},
+	{Title: "Get Out", Year: 2017, Runtime: 104, Genres: []string{"horror", "mystery", "thriller"}},
+}
+
+type seedResult []seededMovie
+
+type seededMovie struct {
+	ID      int64  `json:"id"`
+	Title   string `json:"title"`
+	Year    int32  `json:"year"`
+	Created bool   `json:"created"` // False when the movie already existed
+}
+
+func (r seedResult) table() ([]string, [][]string) {
+	rows := make([][]string, 0, len(r))
+	for _, movie := range r {
+		status := "existing"
+		if movie.Created {
+			status = "created"
+		}
+		rows = append(rows, []string{strconv.FormatInt(movie.ID, 10), movie.Title, strconv.Itoa(int(movie.Year)), status})
+	}
+	return []string{"ID", "TITLE", "YEAR", "STATUS"}, rows
+}
+
+// seedMovies creates the sample movies. A movie with the same title and year is left as is, so that the
+// command can run several times.
+func seedMovies(ctx context.Context, admin *admin, args []string) (result, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", errUsage, args[0])
+	}
+	genres, err := admin.models.Genre.Index(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the genres: %w", err)
+	}
+
+	res := make(seedResult, 0, len(sampleMovies))
+	for _, sample := range sampleMovies {
+		movie := sample
+		movie.Genres = append([]string(nil), sample.Genres...)
+
+		existing, err := findMovie(ctx, admin, movie.Title, movie.Year)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			res = append(res, seededMovie{ID: existing.ID, Title: existing.Title, Year: existing.Year})
+			continue
+		}
+
+		v := validator.New()
+		if data.ValidateMovie(v, &movie, genres); !v.Valid() {
+			return nil, fmt.Errorf("sample movie %s: %w", movie.Title, validationError(v))
+		}
+		if err := admin.models.Movie.Create(ctx, &movie); err != nil {
+			return nil, fmt.Errorf("sample movie %s: %w", movie.Title, err)
+		}
+		res = append(res, seededMovie{ID: movie.ID, Title: movie.Title, Year: movie.Year, Created: true})
+	}
+	return res, nil
+}
+
+// findMovie returns the movie with the title and year, nil if there is none. The title search is a full
+// text match, the candidates are compared to the exact title.
+func findMovie(ctx context.Context, admin *admin, title string, year int32) (*data.Movie, error) {
+	movieFilters := data.MovieFilters{Title: title, YearMin: int(year), YearMax: int(year)}
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafeList: []string{"id"}}
+	movies, _, err := admin.models.Movie.GetAll(ctx, movieFilters, filters)
+	if err != nil {
+		return nil, err
+	}
+	for _, movie := range movies {
+		if strings.EqualFold(movie.Title, title) {
+			return &movie, nil
+		}
+	}
+	return nil, nil
+}
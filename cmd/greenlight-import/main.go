@@ -0,0 +1,276 @@
+// Command greenlight-import creates or updates movies from TMDb-style JSON dump files on local disk.
+//
+// Every file holds either a JSON array of movies or one movie object per line, as in the TMDb exports:
+//
+//	{"id": 278, "imdb_id": "tt0111161", "title": "The Shawshank Redemption", "original_title": "The Shawshank Redemption",
+//	 "original_language": "en", "release_date": "1994-09-23", "runtime": 142, "genres": [{"id": 18, "name": "Drama"}]}
+//
+// Movies are matched by their TMDb ID first, then by their IMDb ID. Matched movies are updated, the others are created.
+//
+//	go run ./cmd/greenlight-import -db-dsn=postgres://greenlight@localhost/greenlight dump1.json dump2.json
+//
+// The DSN is resolved like the API does, from the config file and the GREENLIGHT_* environment, unless
+// -db-dsn is given.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+	"github.com/nguyenanhhao221/greenlight-api/internal/settings"
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// tmdbMovie is a movie record of a TMDb dump, unknown keys are ignored
+type tmdbMovie struct {
+	ID               int64  `json:"id"`
+	IMDbID           string `json:"imdb_id"`
+	Title            string `json:"title"`
+	OriginalTitle    string `json:"original_title"`
+	OriginalLanguage string `json:"original_language"`
+	ReleaseDate      string `json:"release_date"`
+	Runtime          int32  `json:"runtime"`
+	Genres           []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+}
+
+type importer struct {
+	logger *slog.Logger
+	models models.Models
+	genres data.GenreIndex
+	dryRun bool
+
+	created, updated, skipped int
+}
+
+func main() {
+	var configFile, dsn string
+	var dryRun bool
+	flag.StringVar(&configFile, "config", "", "YAML or TOML config file of the API, see also "+settings.EnvPrefix+"CONFIG")
+	flag.StringVar(&dsn, "db-dsn", "", "PostgreSQL DSN (default the db-dsn setting of the config file and the environment)")
+	flag.BoolVar(&dryRun, "dry-run", false, "Validate and match the movies without writing anything")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] dump.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := slog.Default()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	// The .env file is optional, its variables are read like the rest of the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("Error loading .env file", "err", err.Error())
+		os.Exit(1)
+	}
+	if dsn == "" {
+		var err error
+		if dsn, err = settings.DSN(configFile, os.Getenv); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	// Interrupting the import cancels the query in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		logger.Error("error opening database connection", "err", err.Error())
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	imp := &importer{logger: logger, models: models.New(models.NewDB(pool, models.DefaultQueryTimeout)), dryRun: dryRun}
+	if imp.genres, err = imp.models.Genre.Index(ctx); err != nil {
+		logger.Error("error loading the genres", "err", err.Error())
+		os.Exit(1)
+	}
+
+	for _, path := range flag.Args() {
+		if err := imp.importFile(ctx, path); err != nil {
+			logger.Error("error importing file", "file", path, "err", err.Error())
+			os.Exit(1)
+		}
+	}
+	logger.Info("import done", "created", imp.created, "updated", imp.updated, "skipped", imp.skipped, "dry_run", dryRun)
+}
+
+// importFile imports every movie of the file. Invalid movies are skipped, only I/O, JSON syntax and database
+// errors abort the import.
+func (imp *importer) importFile(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	decoder := json.NewDecoder(reader)
+
+	// A JSON array is decoded element by element so that large dumps are never loaded in memory at once
+	isArray, err := startsWithArray(reader)
+	if err != nil {
+		return err
+	}
+	if isArray {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	for n := 1; ; n++ {
+		if isArray && !decoder.More() {
+			return nil
+		}
+		var record tmdbMovie
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := imp.importMovie(ctx, record); err != nil {
+			return fmt.Errorf("record %d (tmdb %d): %w", n, record.ID, err)
+		}
+	}
+}
+
+// startsWithArray reports whether the first non blank character of the reader opens a JSON array
+func startsWithArray(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return false, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b == '[', reader.UnreadByte()
+		}
+	}
+}
+
+func (imp *importer) importMovie(ctx context.Context, record tmdbMovie) error {
+	logger := imp.logger.With("tmdb", record.ID, "title", record.Title)
+
+	externalIDs := []data.ExternalID{{Source: data.ExternalSourceTMDb, ID: strconv.FormatInt(record.ID, 10)}}
+	if record.IMDbID != "" {
+		externalIDs = append(externalIDs, data.ExternalID{Source: data.ExternalSourceIMDb, ID: record.IMDbID})
+	}
+
+	v := validator.New()
+	for i := range externalIDs {
+		data.ValidateExternalID(v, &externalIDs[i])
+	}
+
+	movie, err := imp.match(ctx, externalIDs)
+	if err != nil {
+		return err
+	}
+	existing := movie != nil
+	if !existing {
+		movie = &data.Movie{}
+	}
+
+	movie.Title = record.Title
+	movie.Runtime = data.Runtime(record.Runtime)
+	if releaseDate, err := time.Parse(time.DateOnly, record.ReleaseDate); err == nil {
+		movie.Year = int32(releaseDate.Year())
+	}
+	// Genres unknown to the catalog are dropped rather than rejecting the whole movie
+	movie.Genres = movie.Genres[:0]
+	for _, genre := range record.Genres {
+		if slug, ok := imp.genres.Resolve(genre.Name); ok {
+			movie.Genres = append(movie.Genres, slug)
+		} else {
+			logger.Warn("dropping unknown genre", "genre", genre.Name)
+		}
+	}
+
+	if data.ValidateMovie(v, movie, imp.genres); !v.Valid() {
+		logger.Warn("skipping invalid movie", "errors", v.Errors)
+		imp.skipped++
+		return nil
+	}
+
+	if imp.dryRun {
+		if existing {
+			imp.updated++
+		} else {
+			imp.created++
+		}
+		return nil
+	}
+
+	if existing {
+		if err := imp.models.Movie.Update(ctx, movie); err != nil {
+			return err
+		}
+		imp.updated++
+	} else {
+		if err := imp.models.Movie.Create(ctx, movie); err != nil {
+			return err
+		}
+		imp.created++
+	}
+
+	for _, externalID := range externalIDs {
+		externalID.MovieID = movie.ID
+		if err := imp.models.ExternalID.Put(ctx, &externalID); err != nil {
+			if errors.Is(err, models.ErrDuplicateExternalID) {
+				// Both IDs matched different movies, keep the existing mapping and let someone merge them
+				logger.Warn("external id already belongs to another movie", "source", externalID.Source, "id", externalID.ID)
+				continue
+			}
+			return err
+		}
+	}
+
+	// Keep the title in the original language as a localized title
+	if record.OriginalTitle != "" && record.OriginalTitle != record.Title {
+		title := &data.LocalizedTitle{MovieID: movie.ID, Locale: record.OriginalLanguage, Title: record.OriginalTitle}
+		v := validator.New()
+		if data.ValidateLocalizedTitle(v, title); v.Valid() {
+			if err := imp.models.Localization.PutTitle(ctx, title); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// match returns the movie having one of the external IDs, in order, or nil when there is none
+func (imp *importer) match(ctx context.Context, externalIDs []data.ExternalID) (*data.Movie, error) {
+	for _, externalID := range externalIDs {
+		id, err := imp.models.ExternalID.GetMovieID(ctx, externalID.Source, externalID.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrRecordNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		return imp.models.Movie.Get(ctx, id)
+	}
+	return nil, nil
+}
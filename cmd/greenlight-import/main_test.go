@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/models"
+)
+
+func TestStartsWithArray(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"array", `[{"id": 1}]`, true},
+		{"array after blanks", " \r\n\t[{\"id\": 1}]", true},
+		{"one object per line", "{\"id\": 1}\n{\"id\": 2}\n", false},
+		{"object after blanks", "\n\n{\"id\": 1}", false},
+		{"empty", "", false},
+		{"only blanks", " \n\t", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+			got, err := startsWithArray(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+			// The first non blank character is left for the decoder
+			rest, _ := io.ReadAll(reader)
+			if want := strings.TrimLeft(tt.input, " \t\r\n"); string(rest) != want {
+				t.Errorf("left %q in the reader, want %q", rest, want)
+			}
+		})
+	}
+}
+
+// newTestImporter returns an importer on the in-memory backend
+func newTestImporter(t *testing.T) *importer {
+	t.Helper()
+	imp := &importer{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), models: models.NewMemory()}
+	var err error
+	if imp.genres, err = imp.models.Genre.Index(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return imp
+}
+
+// importDump writes the dump to a file and imports it
+func importDump(t *testing.T, imp *importer, dump string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dump.json")
+	if err := os.WriteFile(path, []byte(dump), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := imp.importFile(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportMatchesTMDbThenIMDb(t *testing.T) {
+	ctx := context.Background()
+	imp := newTestImporter(t)
+
+	importDump(t, imp, `[
+		{"id": 1, "title": "First", "release_date": "1994-09-23", "runtime": 142, "genres": [{"name": "Drama"}]},
+		{"id": 2, "imdb_id": "tt0000002", "title": "Second", "release_date": "1972-03-14", "runtime": 175, "genres": [{"name": "Crime"}]}
+	]`)
+	// The TMDb ID wins over the IMDb ID of the second movie, which is kept on the second movie. The third record
+	// has an unknown TMDb ID and is matched by its IMDb ID.
+	importDump(t, imp, `{"id": 1, "imdb_id": "tt0000002", "title": "First Updated", "release_date": "1994-09-23", "runtime": 142, "genres": [{"name": "Drama"}]}
+{"id": 3, "imdb_id": "tt0000002", "title": "Second Updated", "release_date": "1972-03-14", "runtime": 175, "genres": [{"name": "Crime"}]}
+`)
+
+	if imp.created != 2 || imp.updated != 2 || imp.skipped != 0 {
+		t.Errorf("got %d created, %d updated and %d skipped, want 2, 2 and 0", imp.created, imp.updated, imp.skipped)
+	}
+	for id, want := range map[int64]string{1: "First Updated", 2: "Second Updated"} {
+		movie, err := imp.models.Movie.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if movie.Title != want {
+			t.Errorf("movie %d has title %q, want %q", id, movie.Title, want)
+		}
+	}
+
+	externalIDs, err := imp.models.ExternalID.GetAllForMovies(ctx, []int64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := externalIDs[1][data.ExternalSourceIMDb]; got != "" {
+		t.Errorf("movie 1 has imdb %q, want none as it belongs to movie 2", got)
+	}
+	if got := externalIDs[2][data.ExternalSourceTMDb]; got != "3" {
+		t.Errorf("movie 2 has tmdb %q, want 3", got)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	imp := newTestImporter(t)
+	imp.dryRun = true
+
+	importDump(t, imp, `{"id": 1, "title": "First", "release_date": "1994-09-23", "runtime": 142, "genres": [{"name": "Drama"}]}
+{"id": 2, "title": "", "release_date": "1994-09-23", "runtime": 142, "genres": [{"name": "Drama"}]}
+`)
+
+	if imp.created != 1 || imp.skipped != 1 {
+		t.Errorf("got %d created and %d skipped, want 1 and 1", imp.created, imp.skipped)
+	}
+	if _, err := imp.models.Movie.Get(context.Background(), 1); err == nil {
+		t.Error("the dry run created a movie")
+	}
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// CertificationAges maps the supported certification systems by country to the minimum age of every
+// certification. The ages allow comparing certifications of different countries.
+var CertificationAges = map[string]map[string]int{
+	// MPAA
+	"US": {"G": 0, "PG": 10, "PG-13": 13, "R": 17, "NC-17": 18},
+	// BBFC
+	"GB": {"U": 0, "PG": 8, "12A": 12, "12": 12, "15": 15, "18": 18, "R18": 18},
+}
+
+// Certification is the content rating of a movie in a country. It is also used as the maximum rating a user
+// is willing to see.
+type Certification struct {
+	MovieID int64  `json:"-"`
+	Country string `json:"country"`       // ISO 3166-1 alpha-2 code of the certification system, e.g. US
+	Code    string `json:"certification"` // e.g. PG-13
+}
+
+// MinAge returns the minimum age of the certification, the certification must be valid
+func (c Certification) MinAge() int {
+	return CertificationAges[c.Country][c.Code]
+}
+
+// Allows reports whether a movie with the given certifications is within the limit. The certification of the
+// movie in the country of the limit is used if there is one, otherwise the most restrictive one. Movies without
+// any certification are always allowed. A nil limit allows everything.
+func (c *Certification) Allows(certifications []Certification) bool {
+	if c == nil || len(certifications) == 0 {
+		return true
+	}
+	age := 0
+	for _, certification := range certifications {
+		if certification.Country == c.Country {
+			age = certification.MinAge()
+			break
+		}
+		age = max(age, certification.MinAge())
+	}
+	return age <= c.MinAge()
+}
+
+// ParseCertification parses a certification in the COUNTRY:CERTIFICATION format, e.g. US:PG-13
+func ParseCertification(s string) (*Certification, error) {
+	country, code, ok := strings.Cut(s, ":")
+	certification := &Certification{Country: strings.ToUpper(country), Code: strings.ToUpper(code)}
+	if _, known := CertificationAges[certification.Country][certification.Code]; !ok || !known {
+		return nil, fmt.Errorf("invalid certification %q, must be COUNTRY:CERTIFICATION such as US:PG-13", s)
+	}
+	return certification, nil
+}
+
+func ValidateCertification(v *validator.Validator, certification *Certification) {
+	// Certification codes are case insensitive, e.g. pg-13
+	certification.Country = strings.ToUpper(certification.Country)
+	certification.Code = strings.ToUpper(certification.Code)
+
+	ages, ok := CertificationAges[certification.Country]
+	if !ok {
+		countries := make([]string, 0, len(CertificationAges))
+		for country := range CertificationAges {
+			countries = append(countries, country)
+		}
+		slices.Sort(countries)
+		v.AddError("country", "must be one of "+strings.Join(countries, ", "))
+		return
+	}
+	v.Check(certification.Code != "", "certification", "must be provided")
+	if _, ok := ages[certification.Code]; !ok && certification.Code != "" {
+		v.AddError("certification", "unknown certification for "+certification.Country)
+	}
+}
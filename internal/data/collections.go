@@ -0,0 +1,45 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+type Collection struct {
+	ID          int64              `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Artwork     *StoredImage       `json:"artwork,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Version     int32              `json:"version"`
+	Movies      []CollectionMember `json:"movies,omitempty"` // Only loaded when showing a single collection
+}
+
+// CollectionMember is a movie of a collection, members are ordered by their position starting at 1
+type CollectionMember struct {
+	CollectionID int64  `json:"-"`
+	MovieID      int64  `json:"movie_id"`
+	MovieTitle   string `json:"movie_title,omitempty"`
+	MovieYear    int32  `json:"movie_year,omitempty"`
+	Position     int32  `json:"position"`
+}
+
+// MovieCollection is a collection a movie belongs to, as included in the movie responses
+type MovieCollection struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int32  `json:"position"` // Position of the movie in the collection
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(collection.Description) <= 5_000, "description", "must not be more than 5000 bytes long")
+}
+
+// ValidateCollectionMember checks a member before it is added or moved, a position of 0 means the end of the collection
+func ValidateCollectionMember(v *validator.Validator, member *CollectionMember) {
+	v.Check(member.MovieID > 0, "movie_id", "must be provided")
+	v.Check(member.Position >= 0, "position", "must not be negative (0 appends)")
+}
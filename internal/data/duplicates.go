@@ -0,0 +1,30 @@
+package data
+
+import "github.com/nguyenanhhao221/greenlight-api/internal/validator"
+
+// DuplicateCandidate is a pair of movies which are likely the same movie entered twice
+type DuplicateCandidate struct {
+	NormalizedTitle string `json:"normalized_title"` // Title both movies share once normalized, see MovieModel.GetDuplicates
+	Movie           Movie  `json:"movie"`            // The oldest movie of the pair
+	Duplicate       Movie  `json:"duplicate"`
+}
+
+// DuplicateFilters holds how far apart the year and the runtime of two movies with the same
+// normalized title may be for them to be reported as duplicates
+type DuplicateFilters struct {
+	YearTolerance    int
+	RuntimeTolerance int
+}
+
+func ValidateDuplicateFilters(v *validator.Validator, f DuplicateFilters) {
+	v.Check(f.YearTolerance >= 0, "year_tolerance", "must be a positive integer")
+	v.Check(f.YearTolerance <= 5, "year_tolerance", "must be a maximum of 5")
+	v.Check(f.RuntimeTolerance >= 0, "runtime_tolerance", "must be a positive integer")
+	v.Check(f.RuntimeTolerance <= 60, "runtime_tolerance", "must be a maximum of 60")
+}
+
+// ValidateMerge checks that a movie is merged into another movie
+func ValidateMerge(v *validator.Validator, sourceID, targetID int64) {
+	v.Check(targetID > 0, "into", "must be provided")
+	v.Check(targetID != sourceID, "into", "must be another movie")
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"regexp"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+const (
+	ExternalSourceIMDb = "imdb"
+	ExternalSourceTMDb = "tmdb"
+)
+
+var ExternalSources = []string{ExternalSourceIMDb, ExternalSourceTMDb}
+
+// ExternalIDRX holds the format of the identifiers of every external source
+var ExternalIDRX = map[string]*regexp.Regexp{
+	ExternalSourceIMDb: regexp.MustCompile(`^tt\d{7,}$`),
+	ExternalSourceTMDb: regexp.MustCompile(`^[1-9]\d*$`),
+}
+
+type ExternalID struct {
+	MovieID int64  `json:"-"`
+	Source  string `json:"source"` // Either imdb or tmdb
+	ID      string `json:"id"`     // e.g. tt0111161 for imdb
+}
+
+func ValidateExternalID(v *validator.Validator, externalID *ExternalID) {
+	v.Check(v.In(externalID.Source, ExternalSources), "source", "must be either imdb or tmdb")
+	v.Check(externalID.ID != "", "id", "must be provided")
+	if rx, ok := ExternalIDRX[externalID.Source]; ok && externalID.ID != "" {
+		v.Check(validator.Matches(externalID.ID, rx), "id", "must be a valid "+externalID.Source+" identifier")
+	}
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+const (
+	FacetGenres = "genres"
+	FacetDecade = "decade"
+)
+
+// FacetSafeList holds every facet a client is allowed to request on the movie listing
+var FacetSafeList = []string{FacetGenres, FacetDecade}
+
+// FacetBucket is the number of movies matching the current search that share the same facet value
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets maps a facet name (genres, decade) to its buckets
+type Facets map[string][]FacetBucket
+
+func ValidateFacets(v *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		v.Check(v.In(facet, FacetSafeList), "facets", "invalid facet value")
+	}
+	v.Check(v.Unique(facets), "facets", "must contains unique values")
+}
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"slices"
 	"strings"
@@ -8,10 +10,13 @@ import (
 	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
 )
 
+// ErrInvalidSort is returned when the sort query contains a value that is not in the safe list
+var ErrInvalidSort = errors.New("invalid sort parameter")
+
 type Filters struct {
 	Page         int
 	PageSize     int
-	Sort         string
+	Sort         string // Comma separated list of columns, prefixed with "-" for descending order
 	SortSafeList []string
 }
 
@@ -31,19 +36,48 @@ func (f Filters) Offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
-func (f Filters) SortColumn() string {
-	if slices.Contains(f.SortSafeList, f.Sort) {
-		return strings.TrimPrefix(f.Sort, "-")
+// sortValues splits the comma separated sort query (e.g. "-year,title") into its values
+func (f Filters) sortValues() []string {
+	return strings.Split(f.Sort, ",")
+}
+
+// SortColumn is one of the columns of the requested sort
+type SortColumn struct {
+	Column     string
+	Descending bool
+}
+
+// SortColumns returns the columns of the requested sort in order. Every value is checked against
+// SortSafeList, ErrInvalidSort is returned for a value outside of the list.
+func (f Filters) SortColumns() ([]SortColumn, error) {
+	values := f.sortValues()
+	columns := make([]SortColumn, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(f.SortSafeList, value) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSort, value)
+		}
+		columns = append(columns, SortColumn{Column: strings.TrimPrefix(value, "-"), Descending: strings.HasPrefix(value, "-")})
 	}
-	panic("unsafe sort parameter: " + f.Sort)
+	return columns, nil
 }
 
-func (f Filters) SortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
-	} else {
-		return "ASC"
+// OrderBy builds the ORDER BY expression (e.g. "year DESC, title ASC") for the requested sort.
+// Since column names can't be passed to the database as query arguments, the columns come from
+// [Filters.SortColumns] so that no SQL is built from a value outside of SortSafeList.
+func (f Filters) OrderBy() (string, error) {
+	sortColumns, err := f.SortColumns()
+	if err != nil {
+		return "", err
 	}
+	columns := make([]string, 0, len(sortColumns))
+	for _, column := range sortColumns {
+		direction := "ASC"
+		if column.Descending {
+			direction = "DESC"
+		}
+		columns = append(columns, column.Column+" "+direction)
+	}
+	return strings.Join(columns, ", "), nil
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -52,7 +86,22 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than 0")
 	v.Check(f.PageSize <= 100, "page_size", "must be maximum of 100")
 
-	v.Check(v.In(f.Sort, f.SortSafeList), "sort", "invalid sort value")
+	values := f.sortValues()
+	v.Check(len(values) <= 3, "sort", "must not contains more than 3 columns")
+	columns := make([]string, 0, len(values))
+	for _, value := range values {
+		v.Check(v.In(value, f.SortSafeList), "sort", "invalid sort value: "+value)
+		columns = append(columns, strings.TrimPrefix(value, "-"))
+	}
+	v.Check(v.Unique(columns), "sort", "must not sort by the same column more than once")
+}
+
+// ValidateFields checks that every field requested through a sparse fieldset is in the safe list
+func ValidateFields(v *validator.Validator, fields []string, safeList []string) {
+	for _, field := range fields {
+		v.Check(v.In(field, safeList), "fields", "invalid field value: "+field)
+	}
+	v.Check(v.Unique(fields), "fields", "must contains unique values")
 }
 
 func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// genreSlugRX matches the runs of characters replaced by a dash in a genre slug.
+// It must stay in sync with the slug expression of the genres migration.
+var genreSlugRX = regexp.MustCompile("[^a-z0-9]+")
+
+type Genre struct {
+	ID        int64     `json:"id"`
+	Slug      string    `json:"slug"`              // Unique identifier stored in movies.genres, e.g. science-fiction
+	Name      string    `json:"name"`              // Display name, e.g. Science Fiction
+	Aliases   []string  `json:"aliases,omitempty"` // Other slugs which resolve to this genre, e.g. sci-fi
+	CreatedAt time.Time `json:"-"`
+	Version   int32     `json:"version"`
+}
+
+// SeedGenres holds the genres the genres migration creates, it must stay in sync with the migration
+var SeedGenres = []Genre{
+	{Slug: "action", Name: "Action"},
+	{Slug: "adventure", Name: "Adventure"},
+	{Slug: "animation", Name: "Animation", Aliases: []string{"animated", "cartoon"}},
+	{Slug: "comedy", Name: "Comedy"},
+	{Slug: "crime", Name: "Crime"},
+	{Slug: "documentary", Name: "Documentary", Aliases: []string{"doc", "docs"}},
+	{Slug: "drama", Name: "Drama"},
+	{Slug: "family", Name: "Family"},
+	{Slug: "fantasy", Name: "Fantasy"},
+	{Slug: "history", Name: "History", Aliases: []string{"historical"}},
+	{Slug: "horror", Name: "Horror"},
+	{Slug: "music", Name: "Music", Aliases: []string{"musical"}},
+	{Slug: "mystery", Name: "Mystery"},
+	{Slug: "romance", Name: "Romance", Aliases: []string{"romantic"}},
+	{Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{"sci-fi", "scifi", "sf"}},
+	{Slug: "thriller", Name: "Thriller"},
+	{Slug: "war", Name: "War"},
+	{Slug: "western", Name: "Western"},
+}
+
+// GenreSlug normalizes a free-form genre name into a slug: "Sci-Fi" and "sci fi" both become "sci-fi"
+func GenreSlug(name string) string {
+	return strings.Trim(genreSlugRX.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// GenreIndex resolves the slug of any genre name, slug or alias to the slug of the managed genre
+type GenreIndex map[string]string
+
+func NewGenreIndex(genres []Genre) GenreIndex {
+	index := make(GenreIndex, len(genres))
+	for _, genre := range genres {
+		index[genre.Slug] = genre.Slug
+		for _, alias := range genre.Aliases {
+			index[alias] = genre.Slug
+		}
+	}
+	return index
+}
+
+// Resolve returns the slug of the managed genre matching name, false if the genre is unknown
+func (idx GenreIndex) Resolve(name string) (string, bool) {
+	slug, ok := idx[GenreSlug(name)]
+	return slug, ok
+}
+
+// Normalize resolves every name to its managed genre slug. It is meant for search filters so an unknown
+// genre is kept (as a slug) rather than reported, it will simply match no movie.
+func (idx GenreIndex) Normalize(names []string) []string {
+	slugs := make([]string, 0, len(names))
+	for _, name := range names {
+		if slug, ok := idx.Resolve(name); ok {
+			slugs = append(slugs, slug)
+		} else {
+			slugs = append(slugs, GenreSlug(name))
+		}
+	}
+	return slugs
+}
+
+// ValidateGenre checks the genre and normalizes its slug and aliases. The index is used to make sure the
+// slug and aliases don't already resolve to another genre.
+func ValidateGenre(v *validator.Validator, genre *Genre, index GenreIndex) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	if genre.Slug == "" {
+		genre.Slug = genre.Name
+	}
+	genre.Slug = GenreSlug(genre.Slug)
+	v.Check(genre.Slug != "", "slug", "must contains at least one letter or digit")
+	if existing, ok := index[genre.Slug]; ok {
+		v.Check(existing == genre.Slug, "slug", "is already an alias of the genre "+existing)
+	}
+
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contains more than 20 aliases")
+	aliases := make([]string, 0, len(genre.Aliases))
+	for _, alias := range genre.Aliases {
+		alias = GenreSlug(alias)
+		v.Check(alias != "", "aliases", "must contains at least one letter or digit")
+		v.Check(alias != genre.Slug, "aliases", "must not contains the slug of the genre")
+		if existing, ok := index[alias]; ok {
+			v.Check(existing == genre.Slug, "aliases", "the alias "+alias+" already resolves to the genre "+existing)
+		}
+		aliases = append(aliases, alias)
+	}
+	v.Check(v.Unique(aliases), "aliases", "must contains unique values")
+	genre.Aliases = aliases
+}
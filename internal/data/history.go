@@ -0,0 +1,42 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// WatchEvent records that a user watched a movie at a given time
+type WatchEvent struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"-"`
+	MovieID      int64     `json:"movie_id"`
+	MovieTitle   string    `json:"movie_title,omitempty"`
+	MovieRuntime Runtime   `json:"movie_runtime,omitempty"`
+	WatchedAt    time.Time `json:"watched_at"`
+}
+
+// WatchStats summarises the watch history of a user
+type WatchStats struct {
+	TotalWatched   int          `json:"total_watched"`   // Number of views, a movie watched twice counts twice
+	DistinctMovies int          `json:"distinct_movies"` // Number of different movies watched
+	TotalRuntime   Runtime      `json:"total_runtime"`   // Sum of the runtime of every view
+	FavoriteGenres []GenreCount `json:"favorite_genres"` // Most watched genres first
+	YearlyCounts   []YearCount  `json:"yearly_counts"`   // Number of views per calendar year, most recent first
+}
+
+type GenreCount struct {
+	Genre string `json:"genre"`
+	Count int    `json:"count"`
+}
+
+type YearCount struct {
+	Year  int `json:"year"`
+	Count int `json:"count"`
+}
+
+func ValidateWatchEvent(v *validator.Validator, event *WatchEvent) {
+	v.Check(!event.WatchedAt.IsZero(), "watched_at", "must be provided")
+	v.Check(!event.WatchedAt.After(time.Now()), "watched_at", "must not be in the future")
+	v.Check(event.WatchedAt.Year() >= 1888, "watched_at", "must be greater than or equal to 1888")
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+const (
+	ImageKindPoster = "poster"
+	ImageKindStill  = "still"
+)
+
+var ImageKinds = []string{ImageKindPoster, ImageKindStill}
+
+// ThumbnailWidths maps the name of every generated thumbnail to its width in pixels
+var ThumbnailWidths = map[string]int{
+	"small":  185,
+	"medium": 500,
+}
+
+// StoredImage is an image written to the storage together with its resized thumbnails
+type StoredImage struct {
+	ContentType   string            `json:"content_type"`
+	Width         int32             `json:"width"`
+	Height        int32             `json:"height"`
+	Size          int64             `json:"size"`       // Size of the original image in bytes
+	Key           string            `json:"-"`          // Storage key of the original image
+	ThumbnailKeys map[string]string `json:"-"`          // Storage key of every thumbnail by name
+	URL           string            `json:"url"`        // Filled in from the storage when responding
+	Thumbnails    map[string]string `json:"thumbnails"` // URL of every thumbnail by name, filled in when responding
+}
+
+// Keys returns the storage keys of the original image and of every thumbnail
+func (i StoredImage) Keys() []string {
+	keys := []string{i.Key}
+	for _, key := range i.ThumbnailKeys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+type MovieImage struct {
+	ID      int64  `json:"id"`
+	MovieID int64  `json:"-"`
+	Kind    string `json:"kind"` // Either poster or still
+	StoredImage
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ValidateMovieImage(v *validator.Validator, image *MovieImage) {
+	v.Check(image.Kind != "", "kind", "must be provided")
+	v.Check(v.In(image.Kind, ImageKinds), "kind", "must be either poster or still")
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// WatchlistName is the name given to the watchlist automatically created for every user
+const WatchlistName = "Watchlist"
+
+type List struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Public      bool       `json:"public"` // Public lists can be read by anyone, including anonymous users
+	Watchlist   bool       `json:"-"`      // The watchlist is only reachable through /v1/users/me/watchlist
+	CreatedAt   time.Time  `json:"created_at"`
+	Version     int32      `json:"version"`
+	Items       []ListItem `json:"items,omitempty"`
+}
+
+// ListItem is a movie in a list, items are ordered by their position starting at 1
+type ListItem struct {
+	ListID     int64     `json:"-"`
+	MovieID    int64     `json:"movie_id"`
+	MovieTitle string    `json:"movie_title,omitempty"`
+	MovieYear  int32     `json:"movie_year,omitempty"`
+	Position   int32     `json:"position"`
+	Note       string    `json:"note,omitempty"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(list.Description) <= 2_000, "description", "must not be more than 2000 bytes long")
+}
+
+// ValidateListItem checks an item before it is added or moved, a position of 0 means the end of the list
+func ValidateListItem(v *validator.Validator, item *ListItem) {
+	v.Check(item.MovieID > 0, "movie_id", "must be provided")
+	v.Check(item.Position >= 0, "position", "must not be negative (0 appends)")
+	v.Check(len(item.Note) <= 2_000, "note", "must not be more than 2000 bytes long")
+}
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+	"golang.org/x/text/language"
+)
+
+var CountryRX = regexp.MustCompile("^[A-Z]{2}$")
+
+// custom error type when decoding a YYYY-MM-DD date from json
+var ErrInvalidDateFormat = errors.New("invalid date format, must be YYYY-MM-DD")
+
+// Date is a calendar date without time, encoded in JSON as YYYY-MM-DD
+type Date time.Time
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Time(d).Format(time.DateOnly))), nil
+}
+
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	s, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+	*d = Date(t)
+	return nil
+}
+
+type LocalizedTitle struct {
+	MovieID int64  `json:"-"`
+	Locale  string `json:"locale"` // BCP 47 tag, e.g. pt-BR
+	Title   string `json:"title"`
+}
+
+type ReleaseDate struct {
+	MovieID int64  `json:"-"`
+	Country string `json:"country"` // ISO 3166-1 alpha-2 code, e.g. BR
+	Date    Date   `json:"date"`
+}
+
+// Localization is the localized data of a movie for a given [Locale]
+type Localization struct {
+	Title       string
+	ReleaseDate *Date
+}
+
+// Locale holds the preferences of a client for localized responses
+type Locale struct {
+	Languages []string // Candidate locales in order of preference, e.g. [pt-BR pt en]
+	Country   string   // Country used for the release dates, empty if unknown
+}
+
+// IsZero reports whether the client did not express any preference
+func (l Locale) IsZero() bool {
+	return len(l.Languages) == 0 && l.Country == ""
+}
+
+// ParseLocale builds the [Locale] of a request from the lang parameter, which takes precedence, or from
+// the Accept-Language header. Invalid values are ignored, the response is simply not localized.
+func ParseLocale(lang, acceptLanguage string) Locale {
+	var tags []language.Tag
+	if lang != "" {
+		if tag, err := language.Parse(lang); err == nil {
+			tags = []language.Tag{tag}
+		}
+	} else if acceptLanguage != "" {
+		// The tags are returned sorted by quality
+		tags, _, _ = language.ParseAcceptLanguage(acceptLanguage)
+	}
+
+	var locale Locale
+	for _, tag := range tags {
+		base, _, region := tag.Raw()
+		if base.String() == "und" {
+			continue
+		}
+		// A title in pt-BR is preferred, then falls back to a title in pt
+		for _, candidate := range []string{tag.String(), base.String()} {
+			if !slices.Contains(locale.Languages, candidate) {
+				locale.Languages = append(locale.Languages, candidate)
+			}
+		}
+		if locale.Country == "" && region.String() != "ZZ" {
+			locale.Country = region.String()
+		}
+	}
+	return locale
+}
+
+// CanonicalLocale returns the canonical form of a BCP 47 tag (e.g. "PT-br" becomes "pt-BR")
+// and false if the tag is invalid
+func CanonicalLocale(locale string) (string, bool) {
+	tag, err := language.Parse(locale)
+	if err != nil || tag.String() == "und" {
+		return "", false
+	}
+	return tag.String(), true
+}
+
+func ValidateLocalizedTitle(v *validator.Validator, title *LocalizedTitle) {
+	canonical, ok := CanonicalLocale(title.Locale)
+	v.Check(ok, "locale", "must be a valid BCP 47 language tag")
+	title.Locale = canonical
+
+	v.Check(title.Title != "", "title", "must be provided")
+	v.Check(len(title.Title) <= 500, "title", "must not be 500 bytes long")
+}
+
+func ValidateReleaseDate(v *validator.Validator, releaseDate *ReleaseDate) {
+	v.Check(CountryRX.MatchString(releaseDate.Country), "country", "must be an ISO 3166-1 alpha-2 country code")
+	v.Check(!time.Time(releaseDate.Date).IsZero(), "date", "must be provided")
+	v.Check(time.Time(releaseDate.Date).Year() >= 1888, "date", "must be greater than or equal to 1888")
+}
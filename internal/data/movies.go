@@ -1,6 +1,7 @@
 package data
 
 import (
+	"slices"
 	"time"
 
 	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
@@ -14,9 +15,26 @@ type Movie struct {
 	Runtime   Runtime   `json:"runtime,omitempty"` // Movie run time (in minutes)
 	Genres    []string  `json:"genres,omitempty"`  // Slice of genres for the movie (romance, comedy, etc.)
 	Version   int32     `json:"version"`           // The version number starts at 1 and will be incremented each time the movie information is updated
+
+	AverageRating float64 `json:"average_rating"` // Average of the users ratings, 0 when the movie has not been rated yet
+	RatingCount   int32   `json:"rating_count"`   // Number of users who rated the movie
+
+	Images []MovieImage `json:"images,omitempty"` // Posters and stills, only loaded when responding with the movie
+
+	OriginalTitle string `json:"original_title,omitempty"` // Set when Title has been localized for the client
+	ReleaseDate   *Date  `json:"release_date,omitempty"`   // Release date in the country of the client, if known
+
+	Certifications []Certification   `json:"certifications,omitempty"` // Content rating by country, only loaded when responding
+	ExternalIDs    map[string]string `json:"external_ids,omitempty"`   // IDs in external databases by source, e.g. imdb
+	Collections    []MovieCollection `json:"collections,omitempty"`    // Collections the movie belongs to, only loaded when responding
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// MovieFieldSafeList holds the movie JSON fields a client can select with a sparse fieldset
+var MovieFieldSafeList = []string{"id", "title", "year", "runtime", "genres", "version", "average_rating", "rating_count", "images", "original_title", "release_date", "certifications", "external_ids", "collections"}
+
+// ValidateMovie checks the movie and normalizes its genres to the slugs of the managed genres, aliases are
+// resolved (e.g. "Sci-Fi" becomes "science-fiction") and unknown genres are rejected.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres GenreIndex) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be 500 bytes long")
 
@@ -32,4 +50,59 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contains more than 5 genres")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contains more than 5 genres")
 	v.Check(v.Unique(movie.Genres), "genres", "must contains unique values")
+
+	// Different names can resolve to the same genre, the duplicates are dropped once normalized
+	normalized := make([]string, 0, len(movie.Genres))
+	for _, name := range movie.Genres {
+		slug, ok := genres.Resolve(name)
+		if !ok {
+			v.AddError("genres", "unknown genre: "+name)
+			continue
+		}
+		if !slices.Contains(normalized, slug) {
+			normalized = append(normalized, slug)
+		}
+	}
+	if movie.Genres != nil {
+		movie.Genres = normalized
+	}
+}
+
+// MovieFilters holds the optional conditions used to narrow down the movies returned by a listing.
+// A zero value for any field means that the condition is not applied.
+type MovieFilters struct {
+	Title        string    // Full text match against the movie title
+	Genres       []string  // Movie must contain all of these genres
+	GenresAny    []string  // Movie must contain at least one of these genres
+	GenresNone   []string  // Movie must not contain any of these genres
+	YearMin      int       // Inclusive lower bound of the release year
+	YearMax      int       // Inclusive upper bound of the release year
+	RuntimeMin   int       // Inclusive lower bound of the runtime (in minutes)
+	RuntimeMax   int       // Inclusive upper bound of the runtime (in minutes)
+	CreatedAfter time.Time // Only movies added to our database after this time
+	PersonID     int64     // Only movies the person is credited on
+
+	MaxRating    *Certification // Only movies allowed by the certification limit, see [Certification.Allows]
+	CollectionID int64          // Only movies of the collection
+}
+
+func ValidateMovieFilters(v *validator.Validator, f MovieFilters) {
+	v.Check(len(f.Title) <= 500, "title", "must not be more than 500 bytes long")
+
+	v.Check(f.YearMin == 0 || f.YearMin >= 1888, "year_min", "must be greater than or equal to 1888")
+	v.Check(f.YearMax == 0 || f.YearMax >= 1888, "year_max", "must be greater than or equal to 1888")
+	v.Check(f.YearMax <= time.Now().Year(), "year_max", "must not be in the future")
+	v.Check(f.YearMin == 0 || f.YearMax == 0 || f.YearMin <= f.YearMax, "year_min", "must not be greater than year_max")
+
+	v.Check(f.RuntimeMin >= 0, "runtime_min", "must be positive integer")
+	v.Check(f.RuntimeMax >= 0, "runtime_max", "must be positive integer")
+	v.Check(f.RuntimeMin == 0 || f.RuntimeMax == 0 || f.RuntimeMin <= f.RuntimeMax, "runtime_min", "must not be greater than runtime_max")
+
+	v.Check(len(f.Genres) <= 5, "genres", "must not contains more than 5 genres")
+	v.Check(len(f.GenresAny) <= 20, "genres_any", "must not contains more than 20 genres")
+	v.Check(len(f.GenresNone) <= 20, "genres_none", "must not contains more than 20 genres")
+
+	v.Check(!f.CreatedAfter.After(time.Now()), "created_after", "must not be in the future")
+	v.Check(f.PersonID >= 0, "person_id", "must be a positive integer")
+	v.Check(f.CollectionID >= 0, "collection_id", "must be a positive integer")
 }
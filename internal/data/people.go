@@ -0,0 +1,50 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+const (
+	CreditRoleDirector = "director"
+	CreditRoleWriter   = "writer"
+	CreditRoleActor    = "actor"
+)
+
+var CreditRoles = []string{CreditRoleDirector, CreditRoleWriter, CreditRoleActor}
+
+type Person struct {
+	ID        int64     `json:"id"`                  // Unique integer ID for the person
+	CreatedAt time.Time `json:"-"`                   // Time stamp for when the person is added to our database
+	Name      string    `json:"name"`                // Full name of the person
+	Biography string    `json:"biography,omitempty"` // Short biography of the person
+	Version   int32     `json:"version"`             // Incremented each time the person information is updated
+}
+
+// Credit links a person to a movie with the role they had on it
+type Credit struct {
+	ID            int64  `json:"id"`
+	MovieID       int64  `json:"movie_id"`
+	MovieTitle    string `json:"movie_title,omitempty"`
+	PersonID      int64  `json:"person_id"`
+	PersonName    string `json:"person_name,omitempty"`
+	Role          string `json:"role"`                     // One of director, writer or actor
+	CharacterName string `json:"character_name,omitempty"` // Only relevant for actors
+	BillingOrder  int32  `json:"billing_order"`            // Position of the person in the credits, lowest first
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(len(person.Biography) <= 10_000, "biography", "must not be more than 10000 bytes long")
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(v.In(credit.Role, CreditRoles), "role", "must be one of director, writer or actor")
+	v.Check(credit.Role == CreditRoleActor || credit.CharacterName == "", "character_name", "must only be provided for actors")
+	v.Check(len(credit.CharacterName) <= 500, "character_name", "must not be more than 500 bytes long")
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must be greater or equal to 0")
+}
@@ -0,0 +1,17 @@
+package data
+
+import (
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+// ScoredMovie is a movie returned by the recommendation engine together with its relevance score,
+// the higher the score the more relevant the movie is
+type ScoredMovie struct {
+	Movie Movie   `json:"movie"`
+	Score float64 `json:"score"`
+}
+
+func ValidateRecommendationLimit(v *validator.Validator, limit int) {
+	v.Check(limit > 0, "limit", "must be greater than 0")
+	v.Check(limit <= 50, "limit", "must be maximum of 50")
+}
@@ -0,0 +1,25 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/validator"
+)
+
+type Review struct {
+	ID        int64     `json:"id"`
+	MovieID   int64     `json:"movie_id"`
+	UserID    int64     `json:"user_id"`
+	UserName  string    `json:"user_name,omitempty"`
+	Rating    int16     `json:"rating"`         // Rating from 1 to 10
+	Body      string    `json:"body,omitempty"` // Optional review text
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating != 0, "rating", "must be provided")
+	v.Check(review.Rating >= 1 && review.Rating <= 10, "rating", "must be between 1 and 10")
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
@@ -18,6 +18,8 @@ type User struct {
 	Activated bool      `json:"activated"`
 	CreatedAt time.Time `json:"created_at"`
 	Version   int32     `json:"-"`
+
+	MaxRating *Certification `json:"max_rating"` // Movies rated above are hidden, nil when there is no limit
 }
 
 func (u *User) IsAnonymousUser() bool {
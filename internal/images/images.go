@@ -0,0 +1,87 @@
+// Package images decodes uploaded images and generates their thumbnails using only pure Go libraries
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the decoded size of an image, a small compressed file can otherwise decode into
+// gigabytes of pixels
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// Extensions maps the supported content types to the file extension used when storing them
+var Extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// Decode sniffs the content type from the content itself (the Content-Type sent by the client is not
+// trusted), checks the dimensions before allocating the pixels and decodes the image.
+func Decode(content []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(content)
+
+	var (
+		decode       func(io.Reader) (image.Image, error)
+		decodeConfig func(io.Reader) (image.Config, error)
+	)
+	switch contentType {
+	case "image/jpeg":
+		decode, decodeConfig = jpeg.Decode, jpeg.DecodeConfig
+	case "image/png":
+		decode, decodeConfig = png.Decode, png.DecodeConfig
+	case "image/webp":
+		decode, decodeConfig = webp.Decode, webp.DecodeConfig
+	default:
+		return nil, contentType, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	cfg, err := decodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, contentType, fmt.Errorf("error decoding image config: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, contentType, ErrTooManyPixels
+	}
+
+	img, err := decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, contentType, fmt.Errorf("error decoding image: %w", err)
+	}
+	return img, contentType, nil
+}
+
+// Thumbnail scales the image down to the given width, keeping its aspect ratio.
+// Images already narrower than width are returned untouched, they are never scaled up.
+func Thumbnail(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, bounds, draw.Src, nil)
+	return thumbnail
+}
+
+// EncodeJPEG encodes the image as a JPEG, thumbnails are always stored as JPEG since there is no pure Go
+// WebP encoder
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
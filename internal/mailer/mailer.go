@@ -2,11 +2,13 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"html/template"
 	"log"
 	"time"
 
+	"github.com/nguyenanhhao221/greenlight-api/internal/tracing"
 	"github.com/wneessen/go-mail"
 )
 
@@ -29,7 +31,14 @@ func New(host, username, password, sender string) (*Mailer, error) {
 	}, nil
 }
 
-func (m Mailer) Send(userEmail string, templateFile string, data map[string]any) error {
+// Send renders the template for the user and sends it, within a span when ctx carries one
+func (m Mailer) Send(ctx context.Context, userEmail string, templateFile string, data map[string]any) (err error) {
+	ctx, span := tracing.Start(ctx, "mail.send", tracing.SpanKindClient, tracing.Attr("mail.template", templateFile))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	message := mail.NewMsg()
 	if err := message.From(m.sender); err != nil {
 		log.Printf("failed to set From address: %s\n", err)
@@ -74,7 +83,7 @@ func (m Mailer) Send(userEmail string, templateFile string, data map[string]any)
 	}
 	message.AddAlternativeString("text/html", htmlBody.String())
 
-	if err := m.mailClient.DialAndSend(message); err != nil {
+	if err := m.mailClient.DialAndSendWithContext(ctx, message); err != nil {
 		log.Printf("failed to send mail: %s\n", err)
 		return err
 	}
@@ -0,0 +1,170 @@
+// Package metrics implements counters, gauges and histograms exposed in the Prometheus text exposition
+// format (version 0.0.4), see https://prometheus.io/docs/instrumenting/exposition_formats/.
+//
+// Every metric is registered on a [Registry] which writes all of them, sorted by name, on every scrape.
+// The labels of a metric are declared when it is registered and their values given in the same order when
+// it is updated.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the histogram buckets, in seconds, suited to the latency of a
+// HTTP request
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is registered on a Registry and written on every scrape
+type metric interface {
+	describe() desc
+	write(w io.Writer)
+}
+
+// Registry holds the metrics exposed by a process
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := m.describe()
+	if !validName(d.metricName) || slices.ContainsFunc(d.labels, func(label string) bool { return !validName(label) || label == "le" }) {
+		panic("metrics: invalid name or labels for " + d.metricName)
+	}
+	if slices.ContainsFunc(r.metrics, func(existing metric) bool { return existing.describe().metricName == d.metricName }) {
+		panic("metrics: duplicate metric " + d.metricName)
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric in the text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	slices.SortFunc(metrics, func(a, b metric) int { return strings.Compare(a.describe().metricName, b.describe().metricName) })
+
+	cw := &countingWriter{w: w}
+	for _, m := range metrics {
+		m.write(cw)
+	}
+	return cw.n, cw.err
+}
+
+// Handler serves the metrics to the Prometheus scrapes
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// countingWriter keeps the first error, so that the metrics can be written without checking every write
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// desc describes a metric and its labels
+type desc struct {
+	metricName string
+	help       string
+	kind       string // counter, gauge or histogram
+	labels     []string
+}
+
+func (d desc) describe() desc {
+	return d
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, d.kind)
+}
+
+// labelPairs formats the labels with their values, e.g. {route="/v1/movies",status="200"}. The extra
+// pair is appended when given, it is the le label of the histogram buckets.
+func (d desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (d desc) checkValues(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// labelKey joins the label values into the key of a series, the values can't contain the separator
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// sortedKeys returns the keys of the series in a stable order for the scrapes
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
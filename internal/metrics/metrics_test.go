@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != b.Len() {
+		t.Errorf("WriteTo returned %d bytes, wrote %d", n, b.Len())
+	}
+	return b.String()
+}
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("request_duration_seconds", "Duration of the requests.", []float64{0.1, 0.5, 1}, "route")
+	for _, value := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(value, "/v1/movies")
+	}
+
+	want := `# HELP request_duration_seconds Duration of the requests.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{route="/v1/movies",le="0.1"} 2
+request_duration_seconds_bucket{route="/v1/movies",le="0.5"} 3
+request_duration_seconds_bucket{route="/v1/movies",le="1"} 4
+request_duration_seconds_bucket{route="/v1/movies",le="+Inf"} 5
+request_duration_seconds_sum{route="/v1/movies"} 3.15
+request_duration_seconds_count{route="/v1/movies"} 5
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("errors_total", "Errors by message,\nwith a \\ in the help.", "message")
+	c.Inc("quote \" backslash \\ newline \n end")
+
+	want := `# HELP errors_total Errors by message,\nwith a \\ in the help.
+# TYPE errors_total counter
+errors_total{message="quote \" backslash \\ newline \n end"} 1
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputIsSorted(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("zeta", "Registered first.", "name")
+	r.NewGaugeFunc("alpha", "Registered last.", func() float64 { return 1.5 })
+	c := r.NewCounterVec("mid_total", "Without labels.")
+	g.Set(2, "b")
+	g.Set(1, "a")
+	g.Inc("c")
+	g.Dec("c")
+	c.Add(3)
+
+	want := `# HELP alpha Registered last.
+# TYPE alpha gauge
+alpha 1.5
+# HELP mid_total Without labels.
+# TYPE mid_total counter
+mid_total 3
+# HELP zeta Registered first.
+# TYPE zeta gauge
+zeta{name="a"} 1
+zeta{name="b"} 2
+zeta{name="c"} 0
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterFunc("up_total", "Always one.", func() float64 { return 1 })
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "\nup_total 1\n") {
+		t.Errorf("unexpected body\n%s", rec.Body.String())
+	}
+}
+
+func TestMisusePanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) {
+			r.NewCounterVec("requests_total", "First.")
+			r.NewGaugeFunc("requests_total", "Second.", func() float64 { return 0 })
+		}},
+		{"too few label values", func(r *Registry) {
+			r.NewCounterVec("requests_total", "Requests.", "route", "status").Inc("/v1/movies")
+		}},
+		{"too many label values", func(r *Registry) {
+			r.NewHistogramVec("duration_seconds", "Durations.", DefaultBuckets, "route").Observe(1, "/v1/movies", "200")
+		}},
+		{"invalid metric name", func(r *Registry) {
+			r.NewGaugeVec("1requests", "Invalid.")
+		}},
+		{"reserved le label", func(r *Registry) {
+			r.NewHistogramVec("duration_seconds", "Durations.", DefaultBuckets, "le")
+		}},
+		{"unsorted buckets", func(r *Registry) {
+			r.NewHistogramVec("duration_seconds", "Durations.", []float64{1, 0.5})
+		}},
+		{"decreasing counter", func(r *Registry) {
+			r.NewCounterVec("requests_total", "Requests.").Add(-1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+)
+
+// values holds the value of every series of a counter or a gauge
+type values struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+func newValues(d desc) *values {
+	return &values{desc: d, series: make(map[string]*series)}
+}
+
+func (v *values) add(delta float64, labels []string) {
+	v.checkValues(labels)
+	key := labelKey(labels)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labels...)}
+		v.series[key] = s
+	}
+	s.value += delta
+}
+
+func (v *values) set(value float64, labels []string) {
+	v.checkValues(labels)
+	key := labelKey(labels)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labels...)}
+		v.series[key] = s
+	}
+	s.value = value
+}
+
+func (v *values) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.writeHeader(w)
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(s.labels), formatFloat(s.value))
+	}
+}
+
+// CounterVec is a counter partitioned by labels, it only goes up
+type CounterVec struct {
+	values *values
+}
+
+// NewCounterVec registers a counter, its name should end with _total
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{values: newValues(desc{metricName: name, help: help, kind: "counter", labels: labels})}
+	r.register(c.values)
+	return c
+}
+
+// Inc adds one to the series with the label values
+func (c *CounterVec) Inc(labels ...string) {
+	c.values.add(1, labels)
+}
+
+// Add adds delta to the series with the label values, a negative delta panics
+func (c *CounterVec) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.values.metricName + " can't decrease")
+	}
+	c.values.add(delta, labels)
+}
+
+// GaugeVec is a value partitioned by labels which can go up and down
+type GaugeVec struct {
+	values *values
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{values: newValues(desc{metricName: name, help: help, kind: "gauge", labels: labels})}
+	r.register(g.values)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, labels ...string) {
+	g.values.set(value, labels)
+}
+
+func (g *GaugeVec) Inc(labels ...string) {
+	g.values.add(1, labels)
+}
+
+func (g *GaugeVec) Dec(labels ...string) {
+	g.values.add(-1, labels)
+}
+
+// funcMetric reads its value on every scrape, e.g. from the stats of a connection pool
+type funcMetric struct {
+	desc
+	fn func() float64
+}
+
+func (f funcMetric) write(w io.Writer) {
+	f.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", f.metricName, formatFloat(f.fn()))
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by fn on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(funcMetric{desc: desc{metricName: name, help: help, kind: "gauge"}, fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is returned by fn on every scrape, fn must never decrease
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(funcMetric{desc: desc{metricName: name, help: help, kind: "counter"}, fn: fn})
+}
+
+// HistogramVec counts observations, such as durations, in buckets and is partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets []float64 // Sorted upper bounds, +Inf is implied
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram, buckets are sorted upper bounds such as [DefaultBuckets]
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			panic("metrics: the buckets of " + name + " must be sorted")
+		}
+	}
+	h := &HistogramVec{
+		desc:    desc{metricName: name, help: help, kind: "histogram", labels: labels},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogram),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records the value in the series with the label values
+func (h *HistogramVec) Observe(value float64, labels ...string) {
+	h.checkValues(labels)
+	key := labelKey(labels)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.labels, "le", formatFloat(math.Inf(1))), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(s.labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(s.labels), s.count)
+	}
+}
+
+// validName reports whether the metric or label name is valid in the exposition format
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	return strings.IndexFunc(name, func(r rune) bool {
+		return !(r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+	}) < 0 && !(name[0] >= '0' && name[0] <= '9')
+}
@@ -0,0 +1,314 @@
+// Package migrate applies the SQL migrations of an [fs.FS] to PostgreSQL.
+//
+// The applied version is kept in the schema_migrations table the same way golang-migrate does (a single
+// row with the version and a dirty flag), so that a database migrated by hand with the migrate CLI can be
+// taken over, and the other way around. Like golang-migrate, a migration is not wrapped in a transaction:
+// the version is marked dirty before it runs and clean once it succeeds, a dirty version has to be fixed
+// by hand and then forced.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// NilVersion is the version of a database without any migration applied
+const NilVersion = -1
+
+var (
+	// ErrDirty is returned when the last migration failed, see [Migrator.Force]
+	ErrDirty = errors.New("database is dirty, fix it by hand then force the version")
+	// ErrNoChange is returned when there is no migration to apply
+	ErrNoChange = errors.New("no change")
+	// ErrUnknownVersion is returned for a version without a migration file
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// fileRX matches the name of a migration file, e.g. 000001_create_movies_table.up.sql
+var fileRX = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a pair of up and down SQL files
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus reports whether a migration is applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// Status is the state of the database migrations
+type Status struct {
+	Version    int // NilVersion when no migration is applied
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+// Migrator applies the migrations on a single connection, which holds the advisory lock while migrating
+type Migrator struct {
+	conn       *pgx.Conn
+	fsys       fs.FS
+	migrations []Migration // Sorted by version
+	logger     *slog.Logger
+}
+
+// New reads the migrations of fsys, every version must have both an up and a down file
+func New(conn *pgx.Conn, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.up = entry.Name()
+		} else {
+			migration.down = entry.Name()
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return &Migrator{conn: conn, fsys: fsys, migrations: migrations, logger: logger}, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return ErrNoChange
+	}
+	return m.Goto(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the n last applied migrations
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("the number of migrations to revert must be positive, got %d", n)
+	}
+	return m.locked(ctx, func(current int) error {
+		i := m.index(current)
+		if current == NilVersion {
+			return ErrNoChange
+		}
+		if i < 0 {
+			return fmt.Errorf("%w: the database is at version %d", ErrUnknownVersion, current)
+		}
+		target := NilVersion
+		if i-n >= 0 {
+			target = m.migrations[i-n].Version
+		}
+		return m.migrate(ctx, current, target)
+	})
+}
+
+// Goto applies or reverts the migrations up to version, NilVersion reverts all of them
+func (m *Migrator) Goto(ctx context.Context, version int) error {
+	if version != NilVersion && m.index(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.locked(ctx, func(current int) error {
+		if current != NilVersion && m.index(current) < 0 {
+			return fmt.Errorf("%w: the database is at version %d", ErrUnknownVersion, current)
+		}
+		if current == version {
+			return ErrNoChange
+		}
+		return m.migrate(ctx, current, version)
+	})
+}
+
+// Force sets the version of the database and clears its dirty flag without running any migration. It is
+// used once a failed migration has been fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != NilVersion && m.index(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.unlock()
+
+	return m.setVersion(ctx, version, false)
+}
+
+// Status returns the current version and which migrations are applied
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return Status{}, err
+	}
+	version, dirty, err := m.version(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{Version: version, Dirty: dirty, Migrations: make([]MigrationStatus, 0, len(m.migrations))}
+	for _, migration := range m.migrations {
+		// A dirty version has only been partially applied
+		applied := migration.Version < version || (migration.Version == version && !dirty)
+		status.Migrations = append(status.Migrations, MigrationStatus{Migration: migration, Applied: applied})
+	}
+	return status, nil
+}
+
+// locked runs fn with the advisory lock held and the current clean version of the database
+func (m *Migrator) locked(ctx context.Context, fn func(current int) error) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.unlock()
+
+	current, dirty, err := m.version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w (version %d)", ErrDirty, current)
+	}
+	return fn(current)
+}
+
+// migrate runs the migrations between the versions from and to, one at a time
+func (m *Migrator) migrate(ctx context.Context, from, to int) error {
+	if to > from {
+		for _, migration := range m.migrations {
+			if migration.Version <= from || migration.Version > to {
+				continue
+			}
+			if err := m.run(ctx, migration.up, migration.Version); err != nil {
+				return err
+			}
+			m.logger.Info("migration applied", "version", migration.Version, "name", migration.Name)
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version > from || migration.Version <= to {
+			continue
+		}
+		previous := NilVersion
+		if i > 0 {
+			previous = m.migrations[i-1].Version
+		}
+		if err := m.run(ctx, migration.down, previous); err != nil {
+			return err
+		}
+		m.logger.Info("migration reverted", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+// run executes a migration file, the database is left at version once it succeeds
+func (m *Migrator) run(ctx context.Context, file string, version int) error {
+	query, err := fs.ReadFile(m.fsys, file)
+	if err != nil {
+		return err
+	}
+	if err := m.setVersion(ctx, version, true); err != nil {
+		return err
+	}
+	// Without arguments the statements are sent through the simple protocol, a file can hold several of them
+	if _, err := m.conn.Exec(ctx, string(query)); err != nil {
+		return fmt.Errorf("migration %s failed: %w", file, err)
+	}
+	return m.setVersion(ctx, version, false)
+}
+
+// index returns the index of the migration with the version, -1 if there is none
+func (m *Migrator) index(version int) int {
+	return slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	return err
+}
+
+func (m *Migrator) version(ctx context.Context) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := m.conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NilVersion, false, nil
+	}
+	return version, dirty, err
+}
+
+// setVersion replaces the version row. A clean NilVersion is stored as an empty table, as golang-migrate does.
+func (m *Migrator) setVersion(ctx context.Context, version int, dirty bool) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if version != NilVersion || dirty {
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// lockID derives the advisory lock key from the database name, so that migrating one database does not wait
+// for another one on the same server
+func (m *Migrator) lockID() int64 {
+	return int64(crc32.ChecksumIEEE([]byte(m.conn.Config().Database + "\x00schema_migrations")))
+}
+
+// lock takes the session advisory lock, waiting for any concurrent migration to complete
+func (m *Migrator) lock(ctx context.Context) error {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, m.lockID()); err != nil {
+		return fmt.Errorf("error taking the migration lock: %w", err)
+	}
+	return m.ensureTable(ctx)
+}
+
+func (m *Migrator) unlock() {
+	// The context of the migration may be done already, the lock must be released regardless
+	if _, err := m.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, m.lockID()); err != nil {
+		m.logger.Error("error releasing the migration lock", "err", err.Error())
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type CertificationModel struct {
+	DB *DB
+}
+
+// Put creates or replaces the certification of the movie in the country
+func (m CertificationModel) Put(ctx context.Context, certification *data.Certification) error {
+	query := `
+		INSERT INTO movie_certifications (movie_id, country, certification, min_age)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (movie_id, country) DO UPDATE SET certification = EXCLUDED.certification, min_age = EXCLUDED.min_age;
+	`
+	args := []any{certification.MovieID, certification.Country, certification.Code, certification.MinAge()}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctxWithTimeout, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to put movie certification: %w", err)
+	}
+	return nil
+}
+
+func (m CertificationModel) Delete(ctx context.Context, movieID int64, country string) error {
+	query := `DELETE FROM movie_certifications WHERE movie_id = $1 AND country = $2;`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, movieID, country)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAllForMovies returns the certifications of every given movie keyed by movie ID
+func (m CertificationModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.Certification, error) {
+	query := `
+	SELECT movie_id, country, certification
+	FROM movie_certifications
+	WHERE movie_id = ANY($1)
+	ORDER BY movie_id, country;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, movieIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAllForMovies %w", err)
+	}
+	certifications, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.Certification, error) {
+		var certification data.Certification
+		err := row.Scan(&certification.MovieID, &certification.Country, &certification.Code)
+		return certification, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+
+	byMovie := make(map[int64][]data.Certification, len(movieIDs))
+	for _, certification := range certifications {
+		byMovie[certification.MovieID] = append(byMovie[certification.MovieID], certification)
+	}
+	return byMovie, nil
+}
@@ -0,0 +1,349 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type CollectionModel struct {
+	DB *DB
+}
+
+// collectionColumns are the columns read by [scanCollection]
+const collectionColumns = `id, name, description, artwork_content_type, artwork_width, artwork_height, artwork_size,
+	artwork_key, artwork_thumbnail_keys, created_at, version`
+
+func (m CollectionModel) Create(ctx context.Context, collection *data.Collection) error {
+	query := `
+		INSERT INTO collections (name, description)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRow(ctxWithTimeout, query, collection.Name, collection.Description).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+}
+
+func (m CollectionModel) Get(ctx context.Context, id int64) (*data.Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE id = $1;`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	collection, err := scanCollection(m.DB.QueryRow(ctxWithTimeout, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return collection, nil
+}
+
+// GetAll returns a page of the collections, optionally matching the name
+func (m CollectionModel) GetAll(ctx context.Context, name string, filters data.Filters) ([]data.Collection, data.Metadata, error) {
+	orderBy, err := filters.OrderBy()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER() AS count, %s
+		FROM collections
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s, id ASC
+		LIMIT $2 OFFSET $3;`,
+		collectionColumns, orderBy,
+	)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, name, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	collections := make([]data.Collection, 0)
+	for rows.Next() {
+		collection, err := scanCollection(countedRow{row: rows, count: &totalRecords})
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		collections = append(collections, *collection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	return collections, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m CollectionModel) Update(ctx context.Context, collection *data.Collection) error {
+	// Use version to prevent data race condition to update. This can be consider as optimistic locking
+	query := `
+		UPDATE collections
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version;
+	`
+	args := []any{collection.Name, collection.Description, collection.ID, collection.Version}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+	if err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&collection.Version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the collection and returns it, so that the caller can remove its artwork files
+func (m CollectionModel) Delete(ctx context.Context, id int64) (*data.Collection, error) {
+	query := `DELETE FROM collections WHERE id = $1 RETURNING ` + collectionColumns + `;`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	collection, err := scanCollection(m.DB.QueryRow(ctxWithTimeout, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return collection, nil
+}
+
+// SetArtwork replaces the artwork of the collection, a nil artwork removes it. The previous artwork is
+// returned so that the caller can remove its files, it is nil when there was none.
+func (m CollectionModel) SetArtwork(ctx context.Context, id int64, artwork *data.StoredImage) (*data.StoredImage, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE id = $1 FOR UPDATE;`
+	previous, err := scanCollection(tx.QueryRow(ctxWithTimeout, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	var args []any
+	if artwork != nil {
+		args = []any{artwork.ContentType, artwork.Width, artwork.Height, artwork.Size, artwork.Key, artwork.ThumbnailKeys, id}
+	} else {
+		args = []any{nil, nil, nil, nil, nil, nil, id}
+	}
+	query = `
+		UPDATE collections
+		SET artwork_content_type = $1, artwork_width = $2, artwork_height = $3, artwork_size = $4,
+			artwork_key = $5, artwork_thumbnail_keys = $6, version = version + 1
+		WHERE id = $7;
+	`
+	if _, err := tx.Exec(ctxWithTimeout, query, args...); err != nil {
+		return nil, err
+	}
+	return previous.Artwork, tx.Commit(ctxWithTimeout)
+}
+
+// GetMembers returns the movies of the collection in order, but for the movies rated above maxRating
+func (m CollectionModel) GetMembers(ctx context.Context, collectionID int64, maxRating *data.Certification) ([]data.CollectionMember, error) {
+	query := `
+	SELECT collection_movies.collection_id, collection_movies.movie_id, movies.title, movies.year, collection_movies.position
+	FROM collection_movies
+	INNER JOIN movies ON movies.id = collection_movies.movie_id
+	WHERE collection_movies.collection_id = @collection_id AND movies.deleted_at IS NULL AND ` + ratingCondition + `
+	ORDER BY collection_movies.position;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, ratingArgs(pgx.NamedArgs{"collection_id": collectionID}, maxRating))
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetMembers %w", err)
+	}
+	members, err := pgx.CollectRows(rows, pgx.RowToStructByPos[data.CollectionMember])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return members, nil
+}
+
+// GetAllForMovies returns the collections of every given movie keyed by movie ID
+func (m CollectionModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.MovieCollection, error) {
+	query := `
+	SELECT collection_movies.movie_id, collections.id, collections.name, collection_movies.position
+	FROM collection_movies
+	INNER JOIN collections ON collections.id = collection_movies.collection_id
+	WHERE collection_movies.movie_id = ANY($1)
+	ORDER BY collection_movies.movie_id, collections.name, collections.id;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, movieIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAllForMovies %w", err)
+	}
+	defer rows.Close()
+
+	byMovie := make(map[int64][]data.MovieCollection, len(movieIDs))
+	for rows.Next() {
+		var movieID int64
+		var collection data.MovieCollection
+		if err := rows.Scan(&movieID, &collection.ID, &collection.Name, &collection.Position); err != nil {
+			return nil, err
+		}
+		byMovie[movieID] = append(byMovie[movieID], collection)
+	}
+	return byMovie, rows.Err()
+}
+
+// AddMovie inserts the movie in the collection at its position, shifting the following members down.
+// A position of 0 or past the end appends the movie.
+func (m CollectionModel) AddMovie(ctx context.Context, member *data.CollectionMember) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	if member.Position, err = collectionMovies.makeRoom(ctxWithTimeout, tx, member.CollectionID, member.Position); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO collection_movies (collection_id, movie_id, position)
+		SELECT $1, $2, $3
+		WHERE EXISTS (SELECT 1 FROM movies WHERE id = $2 AND deleted_at IS NULL);
+	`
+	result, err := tx.Exec(ctxWithTimeout, query, member.CollectionID, member.MovieID, member.Position)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505": // unique_violation
+				return ErrDuplicateCollectionMovie
+			case "23503": // foreign_key_violation
+				return ErrRecordNotFound
+			}
+		}
+		return fmt.Errorf("failed to add collection movie: %w", err)
+	}
+	// No row is inserted for a missing or merged movie
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// MoveMovie moves the movie to its new position in the collection, shifting the members in between
+func (m CollectionModel) MoveMovie(ctx context.Context, member *data.CollectionMember) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	if member.Position, err = collectionMovies.move(ctxWithTimeout, tx, member.CollectionID, member.MovieID, member.Position); err != nil {
+		return err
+	}
+
+	query := `UPDATE collection_movies SET position = $3 WHERE collection_id = $1 AND movie_id = $2;`
+	if _, err := tx.Exec(ctxWithTimeout, query, member.CollectionID, member.MovieID, member.Position); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// RemoveMovie deletes the movie from the collection and closes the gap in the positions
+func (m CollectionModel) RemoveMovie(ctx context.Context, collectionID, movieID int64) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	if err := collectionMovies.remove(ctxWithTimeout, tx, collectionID, movieID); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// countedRow prepends the COUNT(*) OVER() column of a paginated query to the destinations of a scan
+type countedRow struct {
+	row   pgx.Row
+	count *int
+}
+
+func (r countedRow) Scan(dest ...any) error {
+	return r.row.Scan(append([]any{r.count}, dest...)...)
+}
+
+// scanCollection scans the [collectionColumns] of a collection
+func scanCollection(row pgx.Row) (*data.Collection, error) {
+	var collection data.Collection
+	var contentType, key *string
+	var width, height *int32
+	var size *int64
+	var thumbnailKeys map[string]string
+	err := row.Scan(
+		&collection.ID,
+		&collection.Name,
+		&collection.Description,
+		&contentType,
+		&width,
+		&height,
+		&size,
+		&key,
+		&thumbnailKeys,
+		&collection.CreatedAt,
+		&collection.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		collection.Artwork = &data.StoredImage{
+			ContentType:   *contentType,
+			Width:         *width,
+			Height:        *height,
+			Size:          *size,
+			Key:           *key,
+			ThumbnailKeys: thumbnailKeys,
+		}
+	}
+	return &collection, nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type CreditModel struct {
+	DB *DB
+}
+
+// Create inserts a new credit for a movie. ErrDuplicateCredit is returned if the person already has
+// the same role (and character) on the movie.
+func (m CreditModel) Create(ctx context.Context, credit *data.Credit) error {
+	query := `
+		INSERT INTO movie_credits (movie_id, person_id, role, character_name, billing_order)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.CharacterName, credit.BillingOrder}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&credit.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505": // unique_violation
+				if pgErr.ConstraintName == "movie_credits_unique_key" {
+					return ErrDuplicateCredit
+				}
+			case "23503": // foreign_key_violation, either the movie or the person does not exist
+				return ErrRecordNotFound
+			}
+		}
+		return fmt.Errorf("failed to create credit: %w", err)
+	}
+	return nil
+}
+
+// GetAllForMovie returns the cast and crew of a movie, ordered by role then billing order
+func (m CreditModel) GetAllForMovie(ctx context.Context, movieID int64) ([]data.Credit, error) {
+	query := `
+	SELECT movie_credits.id, movie_credits.movie_id, movie_credits.person_id, people.name,
+		movie_credits.role, movie_credits.character_name, movie_credits.billing_order
+	FROM movie_credits
+	INNER JOIN people ON people.id = movie_credits.person_id
+	WHERE movie_credits.movie_id = $1
+	ORDER BY movie_credits.role, movie_credits.billing_order, movie_credits.id;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAllForMovie %w", err)
+	}
+	credits, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.Credit, error) {
+		var c data.Credit
+		err := row.Scan(&c.ID, &c.MovieID, &c.PersonID, &c.PersonName, &c.Role, &c.CharacterName, &c.BillingOrder)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return credits, nil
+}
+
+// GetAllForPerson returns the filmography of a person, most recent movies first, but for the movies rated
+// above maxRating
+func (m CreditModel) GetAllForPerson(ctx context.Context, personID int64, maxRating *data.Certification) ([]data.Credit, error) {
+	query := `
+	SELECT movie_credits.id, movie_credits.movie_id, movies.title, movie_credits.person_id,
+		movie_credits.role, movie_credits.character_name, movie_credits.billing_order
+	FROM movie_credits
+	INNER JOIN movies ON movies.id = movie_credits.movie_id
+	WHERE movie_credits.person_id = @person_id AND movies.deleted_at IS NULL AND ` + ratingCondition + `
+	ORDER BY movies.year DESC, movies.id, movie_credits.role;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, ratingArgs(pgx.NamedArgs{"person_id": personID}, maxRating))
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAllForPerson %w", err)
+	}
+	credits, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.Credit, error) {
+		var c data.Credit
+		err := row.Scan(&c.ID, &c.MovieID, &c.MovieTitle, &c.PersonID, &c.Role, &c.CharacterName, &c.BillingOrder)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return credits, nil
+}
+
+// Delete removes a credit from a movie. The movie ID is required so that a credit can only be
+// removed through the movie it belongs to.
+func (m CreditModel) Delete(ctx context.Context, movieID, creditID int64) error {
+	query := `
+		DELETE FROM movie_credits
+		WHERE id = $1 AND movie_id = $2
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, creditID, movieID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
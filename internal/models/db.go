@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultQueryTimeout is the default upper bound of a single model method
+const DefaultQueryTimeout = 3 * time.Second
+
+var (
+	// ErrCanceled is returned when the context of the caller is canceled before the query completes,
+	// e.g. the client disconnected
+	ErrCanceled = errors.New("query canceled")
+	// ErrQueryTimeout is returned when the query runs longer than its deadline
+	ErrQueryTimeout = errors.New("query timeout")
+)
+
+// contextError reports the errors caused by a done context as [ErrCanceled] or [ErrQueryTimeout], other
+// errors are returned as is
+func contextError(err error) error {
+	switch {
+	case err == nil, errors.Is(err, ErrCanceled), errors.Is(err, ErrQueryTimeout):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	default:
+		return err
+	}
+}
+
+// DBTX is implemented by both *pgxpool.Pool and pgx.Tx, so that the models run the same way in or out of a
+// transaction. Begin on a pgx.Tx starts a savepoint.
+type DBTX interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// DB runs the queries of the models on the connection pool, or on a transaction (see [Models.WithTx]).
+// Whatever the statement, an error caused by a done context is reported through [contextError] so that
+// the callers can tell it from a database failure.
+type DB struct {
+	conn         DBTX
+	QueryTimeout time.Duration // Upper bound of every model method
+}
+
+// NewDB returns a DB running the queries on pool, every model method is bounded by queryTimeout
+func NewDB(pool *pgxpool.Pool, queryTimeout time.Duration) *DB {
+	return &DB{conn: pool, QueryTimeout: queryTimeout}
+}
+
+func (db *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := db.conn.Exec(ctx, sql, args...)
+	return tag, contextError(err)
+}
+
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r, err := db.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return rows{r}, nil
+}
+
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return row{db.conn.QueryRow(ctx, sql, args...)}
+}
+
+// Begin starts a transaction, or a savepoint when db already runs on a transaction
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	t, err := db.conn.Begin(ctx)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return tx{t}, nil
+}
+
+type rows struct {
+	pgx.Rows
+}
+
+func (r rows) Scan(dest ...any) error {
+	return contextError(r.Rows.Scan(dest...))
+}
+
+func (r rows) Err() error {
+	return contextError(r.Rows.Err())
+}
+
+type row struct {
+	pgx.Row
+}
+
+func (r row) Scan(dest ...any) error {
+	return contextError(r.Row.Scan(dest...))
+}
+
+// tx is a transaction begun by [DB.Begin], the savepoints begun from it are wrapped as well
+type tx struct {
+	pgx.Tx
+}
+
+func (t tx) Begin(ctx context.Context) (pgx.Tx, error) {
+	nested, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return tx{nested}, nil
+}
+
+func (t tx) Commit(ctx context.Context) error {
+	return contextError(t.Tx.Commit(ctx))
+}
+
+func (t tx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := t.Tx.Exec(ctx, sql, args...)
+	return tag, contextError(err)
+}
+
+func (t tx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r, err := t.Tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return rows{r}, nil
+}
+
+func (t tx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return row{t.Tx.QueryRow(ctx, sql, args...)}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type ExternalIDModel struct {
+	DB *DB
+}
+
+// Put creates or replaces the ID of the movie for the source. It returns [ErrDuplicateExternalID] when the ID
+// already belongs to another movie and [ErrRecordNotFound] for a missing or merged movie.
+func (m ExternalIDModel) Put(ctx context.Context, externalID *data.ExternalID) error {
+	query := `
+		INSERT INTO external_ids (movie_id, source, external_id)
+		SELECT $1, $2, $3
+		WHERE EXISTS (SELECT 1 FROM movies WHERE id = $1 AND deleted_at IS NULL)
+		ON CONFLICT ON CONSTRAINT external_ids_movie_source_key DO UPDATE SET external_id = EXCLUDED.external_id;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, externalID.MovieID, externalID.Source, externalID.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505": // unique_violation
+				return ErrDuplicateExternalID
+			case "23503": // foreign_key_violation
+				return ErrRecordNotFound
+			}
+		}
+		return fmt.Errorf("failed to put external id: %w", err)
+	}
+	// No row is inserted for a missing or merged movie
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m ExternalIDModel) Delete(ctx context.Context, movieID int64, source string) error {
+	query := `DELETE FROM external_ids WHERE movie_id = $1 AND source = $2;`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, movieID, source)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetMovieID returns the ID of the movie which has the external ID. The ID of a merged movie resolves to
+// the movie it was merged into, the merge leaves it on the merged movie when the target had its own.
+func (m ExternalIDModel) GetMovieID(ctx context.Context, source, externalID string) (int64, error) {
+	query := `
+	SELECT COALESCE(movies.merged_into, movies.id)
+	FROM external_ids
+	INNER JOIN movies ON movies.id = external_ids.movie_id
+	LEFT JOIN movies targets ON targets.id = movies.merged_into
+	WHERE external_ids.source = $1 AND external_ids.external_id = $2
+		AND (movies.deleted_at IS NULL OR (targets.id IS NOT NULL AND targets.deleted_at IS NULL));
+	`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	var movieID int64
+	if err := m.DB.QueryRow(ctxWithTimeout, query, source, externalID).Scan(&movieID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, fmt.Errorf("error when QueryRow in GetMovieID %w", err)
+	}
+	return movieID, nil
+}
+
+// GetAllForMovies returns the external IDs of every given movie keyed by movie ID, then by source
+func (m ExternalIDModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64]map[string]string, error) {
+	query := `
+	SELECT movie_id, source, external_id
+	FROM external_ids
+	WHERE movie_id = ANY($1);
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, movieIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAllForMovies %w", err)
+	}
+	defer rows.Close()
+
+	byMovie := make(map[int64]map[string]string, len(movieIDs))
+	for rows.Next() {
+		var externalID data.ExternalID
+		if err := rows.Scan(&externalID.MovieID, &externalID.Source, &externalID.ID); err != nil {
+			return nil, err
+		}
+		if byMovie[externalID.MovieID] == nil {
+			byMovie[externalID.MovieID] = make(map[string]string, len(data.ExternalSources))
+		}
+		byMovie[externalID.MovieID][externalID.Source] = externalID.ID
+	}
+	return byMovie, rows.Err()
+}
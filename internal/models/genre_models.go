@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type GenreModel struct {
+	DB *DB
+}
+
+func (m GenreModel) Create(ctx context.Context, genre *data.Genre) error {
+	query := `
+		INSERT INTO genres (slug, name, aliases)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version;
+	`
+	args := []any{genre.Slug, genre.Name, genre.Aliases}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "genres_slug_key" {
+			return ErrDuplicateGenre
+		}
+		return fmt.Errorf("failed to create genre: %w", err)
+	}
+	return nil
+}
+
+// GetAll returns every managed genre ordered by name
+func (m GenreModel) GetAll(ctx context.Context) ([]data.Genre, error) {
+	query := `
+	SELECT id, slug, name, aliases, created_at, version
+	FROM genres
+	ORDER BY name, id;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAll genres %w", err)
+	}
+	genres, err := pgx.CollectRows(rows, pgx.RowToStructByPos[data.Genre])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return genres, nil
+}
+
+// Index returns a [data.GenreIndex] of every managed genre, used to validate and normalize movie genres
+func (m GenreModel) Index(ctx context.Context) (data.GenreIndex, error) {
+	genres, err := m.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewGenreIndex(genres), nil
+}
+
+func (m GenreModel) Get(ctx context.Context, id int64) (*data.Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+	SELECT id, slug, name, aliases, created_at, version
+	FROM genres
+	WHERE id = $1;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	var genre data.Genre
+	err := m.DB.QueryRow(ctxWithTimeout, query, id).Scan(&genre.ID, &genre.Slug, &genre.Name, &genre.Aliases, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &genre, nil
+}
+
+// Update saves the genre. When the slug changes, the movies using the previous slug are updated in the
+// same transaction so that they keep pointing to the genre.
+func (m GenreModel) Update(ctx context.Context, genre *data.Genre, previousSlug string) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	// Use version to prevent data race condition to update. This can be consider as optimistic locking
+	query := `
+		UPDATE genres
+		SET slug = $1, name = $2, aliases = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version;
+	`
+	args := []any{genre.Slug, genre.Name, genre.Aliases, genre.ID, genre.Version}
+	if err := tx.QueryRow(ctxWithTimeout, query, args...).Scan(&genre.Version); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		case errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "genres_slug_key":
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	if genre.Slug != previousSlug {
+		query = `
+			UPDATE movies
+			SET genres = array_replace(genres, $1, $2), version = version + 1
+			WHERE genres @> ARRAY[$1];
+		`
+		if _, err := tx.Exec(ctxWithTimeout, query, previousSlug, genre.Slug); err != nil {
+			return fmt.Errorf("error renaming genre of movies %w", err)
+		}
+	}
+
+	return tx.Commit(ctxWithTimeout)
+}
+
+// Delete removes a genre, ErrGenreInUse is returned if a movie still has it
+func (m GenreModel) Delete(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM genres
+		WHERE id = $1
+		RETURNING NOT EXISTS (SELECT 1 FROM movies WHERE genres @> ARRAY[genres.slug]);
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	var unused bool
+	if err := tx.QueryRow(ctxWithTimeout, query, id).Scan(&unused); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	// Roll back the deletion rather than leaving movies with a genre that is not managed anymore
+	if !unused {
+		return ErrGenreInUse
+	}
+	return tx.Commit(ctxWithTimeout)
+}
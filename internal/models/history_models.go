@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type HistoryModel struct {
+	DB *DB
+}
+
+// Create records a view of the movie by the user
+func (m HistoryModel) Create(ctx context.Context, event *data.WatchEvent) error {
+	query := `
+		INSERT INTO watch_history (user_id, movie_id, watched_at)
+		SELECT $1, $2, $3
+		WHERE EXISTS (SELECT 1 FROM movies WHERE id = $2 AND deleted_at IS NULL)
+		RETURNING id;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctxWithTimeout, query, event.UserID, event.MovieID, event.WatchedAt).Scan(&event.ID)
+	if err != nil {
+		// No row is inserted for a missing or merged movie
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to record watch event: %w", err)
+	}
+	return nil
+}
+
+// GetAllForUser returns a page of the watch history of the user
+func (m HistoryModel) GetAllForUser(ctx context.Context, userID int64, filters data.Filters) ([]data.WatchEvent, data.Metadata, error) {
+	orderBy, err := filters.OrderBy()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER() AS count, watch_history.id, watch_history.user_id, watch_history.movie_id,
+			movies.title, movies.runtime, watch_history.watched_at
+		FROM watch_history
+		INNER JOIN movies ON movies.id = watch_history.movie_id
+		WHERE watch_history.user_id = $1 AND movies.deleted_at IS NULL
+		ORDER BY %s, watch_history.id DESC
+		LIMIT $2 OFFSET $3;`,
+		orderBy,
+	)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, userID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := make([]data.WatchEvent, 0)
+	for rows.Next() {
+		var event data.WatchEvent
+		err := rows.Scan(&totalRecords, &event.ID, &event.UserID, &event.MovieID, &event.MovieTitle, &event.MovieRuntime, &event.WatchedAt)
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	return events, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetStats computes the statistics of the watch history of the user
+func (m HistoryModel) GetStats(ctx context.Context, userID int64) (*data.WatchStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	stats := data.WatchStats{}
+
+	query := `
+	SELECT COUNT(*), COUNT(DISTINCT watch_history.movie_id), COALESCE(SUM(movies.runtime), 0)
+	FROM watch_history
+	INNER JOIN movies ON movies.id = watch_history.movie_id
+	WHERE watch_history.user_id = $1 AND movies.deleted_at IS NULL;
+	`
+	err := m.DB.QueryRow(ctx, query, userID).Scan(&stats.TotalWatched, &stats.DistinctMovies, &stats.TotalRuntime)
+	if err != nil {
+		return nil, fmt.Errorf("error when QueryRow in GetStats %w", err)
+	}
+
+	query = `
+	SELECT genre, COUNT(*) AS count
+	FROM watch_history
+	INNER JOIN movies ON movies.id = watch_history.movie_id, unnest(movies.genres) AS genre
+	WHERE watch_history.user_id = $1 AND movies.deleted_at IS NULL
+	GROUP BY genre
+	ORDER BY count DESC, genre ASC
+	LIMIT 5;
+	`
+	rows, err := m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetStats %w", err)
+	}
+	stats.FavoriteGenres, err = pgx.CollectRows(rows, pgx.RowToStructByPos[data.GenreCount])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+
+	query = `
+	SELECT date_part('year', watched_at)::integer AS year, COUNT(*) AS count
+	FROM watch_history
+	WHERE user_id = $1
+	GROUP BY year
+	ORDER BY year DESC;
+	`
+	rows, err = m.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetStats %w", err)
+	}
+	stats.YearlyCounts, err = pgx.CollectRows(rows, pgx.RowToStructByPos[data.YearCount])
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type ImageModel struct {
+	DB *DB
+}
+
+// Create records an image which has already been written to the storage
+func (m ImageModel) Create(ctx context.Context, image *data.MovieImage) error {
+	query := `
+		INSERT INTO movie_images (movie_id, kind, content_type, width, height, size, storage_key, thumbnail_keys)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at;
+	`
+	args := []any{image.MovieID, image.Kind, image.ContentType, image.Width, image.Height, image.Size, image.Key, image.ThumbnailKeys}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&image.ID, &image.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to create movie image: %w", err)
+	}
+	return nil
+}
+
+// GetAllForMovies returns the images of every given movie keyed by movie ID, posters first
+func (m ImageModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.MovieImage, error) {
+	query := `
+	SELECT id, movie_id, kind, content_type, width, height, size, storage_key, thumbnail_keys, created_at
+	FROM movie_images
+	WHERE movie_id = ANY($1)
+	ORDER BY movie_id, kind, id;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, movieIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetAllForMovies %w", err)
+	}
+	images, err := pgx.CollectRows(rows, scanImage)
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+
+	byMovie := make(map[int64][]data.MovieImage, len(movieIDs))
+	for _, image := range images {
+		byMovie[image.MovieID] = append(byMovie[image.MovieID], image)
+	}
+	return byMovie, nil
+}
+
+// Delete removes the image record of the movie and returns it, so that the caller can remove its files
+func (m ImageModel) Delete(ctx context.Context, movieID, imageID int64) (*data.MovieImage, error) {
+	query := `
+		DELETE FROM movie_images
+		WHERE id = $1 AND movie_id = $2
+		RETURNING id, movie_id, kind, content_type, width, height, size, storage_key, thumbnail_keys, created_at;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, imageID, movieID)
+	if err != nil {
+		return nil, err
+	}
+	image, err := pgx.CollectExactlyOneRow(rows, scanImage)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
+func scanImage(row pgx.CollectableRow) (data.MovieImage, error) {
+	var image data.MovieImage
+	err := row.Scan(
+		&image.ID,
+		&image.MovieID,
+		&image.Kind,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.Size,
+		&image.Key,
+		&image.ThumbnailKeys,
+		&image.CreatedAt,
+	)
+	return image, err
+}
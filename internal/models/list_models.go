@@ -0,0 +1,292 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type ListModel struct {
+	DB *DB
+}
+
+func (m ListModel) Create(ctx context.Context, list *data.List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description, is_public)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version;
+	`
+	args := []any{list.UserID, list.Name, list.Description, list.Public}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+func (m ListModel) Get(ctx context.Context, id int64) (*data.List, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+	SELECT id, user_id, name, description, is_public, is_watchlist, created_at, version
+	FROM lists
+	WHERE id = $1;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	list, err := scanList(m.DB.QueryRow(ctxWithTimeout, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetWatchlist returns the watchlist of the user, creating it on first use
+func (m ListModel) GetWatchlist(ctx context.Context, userID int64) (*data.List, error) {
+	query := `
+	WITH created AS (
+		INSERT INTO lists (user_id, name, is_watchlist)
+		VALUES ($1, $2, true)
+		ON CONFLICT (user_id) WHERE is_watchlist DO NOTHING
+		RETURNING id, user_id, name, description, is_public, is_watchlist, created_at, version
+	)
+	SELECT id, user_id, name, description, is_public, is_watchlist, created_at, version FROM created
+	UNION ALL
+	SELECT id, user_id, name, description, is_public, is_watchlist, created_at, version
+	FROM lists
+	WHERE user_id = $1 AND is_watchlist;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	list, err := scanList(m.DB.QueryRow(ctxWithTimeout, query, userID, data.WatchlistName))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return list, err
+	}
+	// A concurrent first access created the watchlist after the snapshot of the statement, so that neither the
+	// insert nor the select returned it. A new statement sees it.
+	list, err = scanList(m.DB.QueryRow(ctxWithTimeout, `
+	SELECT id, user_id, name, description, is_public, is_watchlist, created_at, version
+	FROM lists
+	WHERE user_id = $1 AND is_watchlist;
+	`, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetAllForUser returns the custom lists of the user, the watchlist is not included
+func (m ListModel) GetAllForUser(ctx context.Context, userID int64, filters data.Filters) ([]data.List, data.Metadata, error) {
+	orderBy, err := filters.OrderBy()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER() AS count, id, user_id, name, description, is_public, is_watchlist, created_at, version
+		FROM lists
+		WHERE user_id = $1 AND NOT is_watchlist
+		ORDER BY %s, id ASC
+		LIMIT $2 OFFSET $3;`,
+		orderBy,
+	)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, userID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	lists := make([]data.List, 0)
+	for rows.Next() {
+		var list data.List
+		err := rows.Scan(&totalRecords, &list.ID, &list.UserID, &list.Name, &list.Description, &list.Public, &list.Watchlist, &list.CreatedAt, &list.Version)
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	return lists, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m ListModel) Update(ctx context.Context, list *data.List) error {
+	// Use version to prevent data race condition to update. This can be consider as optimistic locking
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, is_public = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version;
+	`
+	args := []any{list.Name, list.Description, list.Public, list.ID, list.Version}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+	if err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&list.Version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (m ListModel) Delete(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM lists
+		WHERE id = $1 AND NOT is_watchlist
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetItems returns the movies of a list in order, but for the movies rated above maxRating
+func (m ListModel) GetItems(ctx context.Context, listID int64, maxRating *data.Certification) ([]data.ListItem, error) {
+	query := `
+	SELECT list_items.list_id, list_items.movie_id, movies.title, movies.year,
+		list_items.position, list_items.note, list_items.added_at
+	FROM list_items
+	INNER JOIN movies ON movies.id = list_items.movie_id
+	WHERE list_items.list_id = @list_id AND movies.deleted_at IS NULL AND ` + ratingCondition + `
+	ORDER BY list_items.position;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, ratingArgs(pgx.NamedArgs{"list_id": listID}, maxRating))
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetItems %w", err)
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.ListItem, error) {
+		var item data.ListItem
+		err := row.Scan(&item.ListID, &item.MovieID, &item.MovieTitle, &item.MovieYear, &item.Position, &item.Note, &item.AddedAt)
+		return item, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return items, nil
+}
+
+// AddItem inserts a movie at the item position, the following items are shifted down.
+// A position of 0 (or past the end of the list) appends the movie at the end.
+func (m ListModel) AddItem(ctx context.Context, item *data.ListItem) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	if item.Position, err = listItems.makeRoom(ctxWithTimeout, tx, item.ListID, item.Position); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO list_items (list_id, movie_id, position, note)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM movies WHERE id = $2 AND deleted_at IS NULL)
+		RETURNING added_at;
+	`
+	args := []any{item.ListID, item.MovieID, item.Position, item.Note}
+	if err := tx.QueryRow(ctxWithTimeout, query, args...).Scan(&item.AddedAt); err != nil {
+		// No row is inserted for a missing or merged movie
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505": // unique_violation
+				return ErrDuplicateListItem
+			case "23503": // foreign_key_violation
+				return ErrRecordNotFound
+			}
+		}
+		return fmt.Errorf("failed to add list item: %w", err)
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// UpdateItem saves the note of the item and moves it to its new position, shifting the items in between
+func (m ListModel) UpdateItem(ctx context.Context, item *data.ListItem) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	if item.Position, err = listItems.move(ctxWithTimeout, tx, item.ListID, item.MovieID, item.Position); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE list_items SET position = $3, note = $4
+		WHERE list_id = $1 AND movie_id = $2
+		RETURNING added_at;
+	`
+	args := []any{item.ListID, item.MovieID, item.Position, item.Note}
+	if err := tx.QueryRow(ctxWithTimeout, query, args...).Scan(&item.AddedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// RemoveItem deletes the movie from the list and closes the gap in the positions
+func (m ListModel) RemoveItem(ctx context.Context, listID, movieID int64) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	if err := listItems.remove(ctxWithTimeout, tx, listID, movieID); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+func scanList(row pgx.Row) (*data.List, error) {
+	var list data.List
+	err := row.Scan(&list.ID, &list.UserID, &list.Name, &list.Description, &list.Public, &list.Watchlist, &list.CreatedAt, &list.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
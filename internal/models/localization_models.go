@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type LocalizationModel struct {
+	DB *DB
+}
+
+// PutTitle creates or replaces the title of the movie for the locale
+func (m LocalizationModel) PutTitle(ctx context.Context, title *data.LocalizedTitle) error {
+	query := `
+		INSERT INTO movie_titles (movie_id, locale, title)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (movie_id, locale) DO UPDATE SET title = EXCLUDED.title;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctxWithTimeout, query, title.MovieID, title.Locale, title.Title)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to put movie title: %w", err)
+	}
+	return nil
+}
+
+func (m LocalizationModel) DeleteTitle(ctx context.Context, movieID int64, locale string) error {
+	query := `DELETE FROM movie_titles WHERE movie_id = $1 AND locale = $2;`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, movieID, locale)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// PutReleaseDate creates or replaces the release date of the movie in the country
+func (m LocalizationModel) PutReleaseDate(ctx context.Context, releaseDate *data.ReleaseDate) error {
+	query := `
+		INSERT INTO movie_release_dates (movie_id, country, release_date)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (movie_id, country) DO UPDATE SET release_date = EXCLUDED.release_date;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctxWithTimeout, query, releaseDate.MovieID, releaseDate.Country, time.Time(releaseDate.Date))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to put movie release date: %w", err)
+	}
+	return nil
+}
+
+func (m LocalizationModel) DeleteReleaseDate(ctx context.Context, movieID int64, country string) error {
+	query := `DELETE FROM movie_release_dates WHERE movie_id = $1 AND country = $2;`
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, movieID, country)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAllForMovie returns every localized title and release date of the movie
+func (m LocalizationModel) GetAllForMovie(ctx context.Context, movieID int64) ([]data.LocalizedTitle, []data.ReleaseDate, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, `SELECT movie_id, locale, title FROM movie_titles WHERE movie_id = $1 ORDER BY locale;`, movieID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when Query titles in GetAllForMovie %w", err)
+	}
+	titles, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.LocalizedTitle, error) {
+		var title data.LocalizedTitle
+		err := row.Scan(&title.MovieID, &title.Locale, &title.Title)
+		return title, err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+
+	rows, err = m.DB.Query(ctxWithTimeout, `SELECT movie_id, country, release_date FROM movie_release_dates WHERE movie_id = $1 ORDER BY country;`, movieID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error when Query release dates in GetAllForMovie %w", err)
+	}
+	releaseDates, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.ReleaseDate, error) {
+		var releaseDate data.ReleaseDate
+		var date time.Time
+		err := row.Scan(&releaseDate.MovieID, &releaseDate.Country, &date)
+		releaseDate.Date = data.Date(date)
+		return releaseDate, err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return titles, releaseDates, nil
+}
+
+// Localize returns the localization of every given movie for the locale, keyed by movie ID. The title is
+// the one of the first matching candidate of locale.Languages. Movies without any localized data are absent.
+func (m LocalizationModel) Localize(ctx context.Context, movieIDs []int64, locale data.Locale) (map[int64]data.Localization, error) {
+	query := `
+	SELECT movies.id, t.title, r.release_date
+	FROM movies
+	LEFT JOIN LATERAL (
+		SELECT title FROM movie_titles
+		WHERE movie_titles.movie_id = movies.id AND movie_titles.locale = ANY($2)
+		ORDER BY array_position($2, movie_titles.locale)
+		LIMIT 1
+	) t ON true
+	LEFT JOIN movie_release_dates r ON r.movie_id = movies.id AND r.country = $3
+	WHERE movies.id = ANY($1) AND (t.title IS NOT NULL OR r.release_date IS NOT NULL);
+	`
+	languages := locale.Languages
+	if languages == nil {
+		languages = []string{}
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctxWithTimeout, query, movieIDs, languages, locale.Country)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in Localize %w", err)
+	}
+	defer rows.Close()
+
+	localizations := make(map[int64]data.Localization, len(movieIDs))
+	for rows.Next() {
+		var id int64
+		var title *string
+		var releaseDate *time.Time
+		if err := rows.Scan(&id, &title, &releaseDate); err != nil {
+			return nil, err
+		}
+		var localization data.Localization
+		if title != nil {
+			localization.Title = *title
+		}
+		if releaseDate != nil {
+			date := data.Date(*releaseDate)
+			localization.ReleaseDate = &date
+		}
+		localizations[id] = localization
+	}
+	return localizations, rows.Err()
+}
@@ -0,0 +1,949 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+// copyGenre returns a copy of the genre which doesn't share its aliases with the stored one
+func copyGenre(genre data.Genre) data.Genre {
+	genre.Aliases = slices.Clone(genre.Aliases)
+	return genre
+}
+
+// copyStoredImage returns a copy of the image which doesn't share its thumbnails with the stored one
+func copyStoredImage(image data.StoredImage) data.StoredImage {
+	image.ThumbnailKeys = maps.Clone(image.ThumbnailKeys)
+	image.Thumbnails = maps.Clone(image.Thumbnails)
+	return image
+}
+
+// copyCollection returns a copy of the collection which doesn't share its artwork with the stored one
+func copyCollection(collection data.Collection) data.Collection {
+	if collection.Artwork != nil {
+		artwork := copyStoredImage(*collection.Artwork)
+		collection.Artwork = &artwork
+	}
+	collection.Movies = nil
+	return collection
+}
+
+// hasMovie reports whether the movie exists, merged movies included, as the foreign keys on movies do.
+// The caller must hold the lock.
+func (s *memoryStore) hasMovie(id int64) bool {
+	_, ok := s.movies[id]
+	return ok
+}
+
+// hasLiveMovie reports whether the movie exists and has not been merged, the caller must hold the lock
+func (s *memoryStore) hasLiveMovie(id int64) bool {
+	stored, ok := s.movies[id]
+	return ok && !stored.deleted
+}
+
+// certificationsOf returns the certifications of the movie, the caller must hold the lock
+func (s *memoryStore) certificationsOf(movieID int64) []data.Certification {
+	var certifications []data.Certification
+	for _, certification := range s.certifications {
+		if certification.MovieID == movieID {
+			certifications = append(certifications, certification)
+		}
+	}
+	return certifications
+}
+
+// allows reports whether the movie is within the rating limit, it mirrors [ratingCondition]. The caller
+// must hold the lock.
+func (s *memoryStore) allows(movieID int64, maxRating *data.Certification) bool {
+	return maxRating.Allows(s.certificationsOf(movieID))
+}
+
+// MemoryGenreModel is the in-memory [GenreRepository], it starts with the genres of [data.SeedGenres]
+type MemoryGenreModel struct {
+	store *memoryStore
+}
+
+// genreBySlug returns the stored genre with the slug, the caller must hold the lock
+func (s *memoryStore) genreBySlug(slug string) *data.Genre {
+	for _, genre := range s.genres {
+		if genre.Slug == slug {
+			return genre
+		}
+	}
+	return nil
+}
+
+func (m MemoryGenreModel) Create(ctx context.Context, genre *data.Genre) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.genreBySlug(genre.Slug) != nil {
+		return ErrDuplicateGenre
+	}
+	m.store.lastGenreID++
+	genre.ID = m.store.lastGenreID
+	genre.CreatedAt = time.Now().Truncate(time.Second)
+	genre.Version = 1
+
+	stored := copyGenre(*genre)
+	m.store.genres[genre.ID] = &stored
+	return nil
+}
+
+func (m MemoryGenreModel) GetAll(ctx context.Context) ([]data.Genre, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	genres := make([]data.Genre, 0, len(m.store.genres))
+	for _, genre := range m.store.genres {
+		genres = append(genres, copyGenre(*genre))
+	}
+	m.store.mu.RUnlock()
+
+	slices.SortFunc(genres, func(a, b data.Genre) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return genres, nil
+}
+
+func (m MemoryGenreModel) Index(ctx context.Context) (data.GenreIndex, error) {
+	genres, err := m.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewGenreIndex(genres), nil
+}
+
+func (m MemoryGenreModel) Get(ctx context.Context, id int64) (*data.Genre, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, ok := m.store.genres[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	genre := copyGenre(*stored)
+	return &genre, nil
+}
+
+// Update saves the genre, the movies using the previous slug are renamed as with [GenreModel.Update]
+func (m MemoryGenreModel) Update(ctx context.Context, genre *data.Genre, previousSlug string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.genres[genre.ID]
+	if !ok || stored.Version != genre.Version {
+		return ErrEditConflict
+	}
+	if other := m.store.genreBySlug(genre.Slug); other != nil && other.ID != genre.ID {
+		return ErrDuplicateGenre
+	}
+
+	updated := copyGenre(*genre)
+	updated.CreatedAt = stored.CreatedAt
+	updated.Version = stored.Version + 1
+	m.store.genres[genre.ID] = &updated
+	genre.Version = updated.Version
+
+	if genre.Slug != previousSlug {
+		for _, movie := range m.store.movies {
+			if i := slices.Index(movie.movie.Genres, previousSlug); i >= 0 {
+				movie.movie.Genres[i] = genre.Slug
+				movie.movie.Version++
+			}
+		}
+	}
+	return nil
+}
+
+// Delete removes a genre, ErrGenreInUse is returned if a movie still has it
+func (m MemoryGenreModel) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	genre, ok := m.store.genres[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	for _, movie := range m.store.movies {
+		if slices.Contains(movie.movie.Genres, genre.Slug) {
+			return ErrGenreInUse
+		}
+	}
+	delete(m.store.genres, id)
+	return nil
+}
+
+// MemoryImageModel is the in-memory [ImageRepository], it only holds the records of the images which are
+// written to the storage like with PostgreSQL
+type MemoryImageModel struct {
+	store *memoryStore
+}
+
+func (m MemoryImageModel) Create(ctx context.Context, image *data.MovieImage) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if !m.store.hasMovie(image.MovieID) {
+		return ErrRecordNotFound
+	}
+	m.store.lastImageID++
+	image.ID = m.store.lastImageID
+	image.CreatedAt = time.Now().Truncate(time.Second)
+
+	stored := *image
+	stored.StoredImage = copyStoredImage(image.StoredImage)
+	m.store.images = append(m.store.images, stored)
+	return nil
+}
+
+func (m MemoryImageModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.MovieImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	byMovie := make(map[int64][]data.MovieImage, len(movieIDs))
+	for _, image := range m.store.images {
+		if slices.Contains(movieIDs, image.MovieID) {
+			image.StoredImage = copyStoredImage(image.StoredImage)
+			byMovie[image.MovieID] = append(byMovie[image.MovieID], image)
+		}
+	}
+	m.store.mu.RUnlock()
+
+	// Posters first, as with [ImageModel.GetAllForMovies]
+	for _, images := range byMovie {
+		slices.SortFunc(images, func(a, b data.MovieImage) int {
+			return cmp.Or(strings.Compare(a.Kind, b.Kind), cmp.Compare(a.ID, b.ID))
+		})
+	}
+	return byMovie, nil
+}
+
+func (m MemoryImageModel) Delete(ctx context.Context, movieID, imageID int64) (*data.MovieImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	i := slices.IndexFunc(m.store.images, func(image data.MovieImage) bool {
+		return image.ID == imageID && image.MovieID == movieID
+	})
+	if i < 0 {
+		return nil, ErrRecordNotFound
+	}
+	image := m.store.images[i]
+	m.store.images = slices.Delete(m.store.images, i, i+1)
+	return &image, nil
+}
+
+// MemoryCertificationModel is the in-memory [CertificationRepository]
+type MemoryCertificationModel struct {
+	store *memoryStore
+}
+
+func (m MemoryCertificationModel) Put(ctx context.Context, certification *data.Certification) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if !m.store.hasMovie(certification.MovieID) {
+		return ErrRecordNotFound
+	}
+	i := slices.IndexFunc(m.store.certifications, func(c data.Certification) bool {
+		return c.MovieID == certification.MovieID && c.Country == certification.Country
+	})
+	if i < 0 {
+		m.store.certifications = append(m.store.certifications, *certification)
+	} else {
+		m.store.certifications[i] = *certification
+	}
+	return nil
+}
+
+func (m MemoryCertificationModel) Delete(ctx context.Context, movieID int64, country string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	before := len(m.store.certifications)
+	m.store.certifications = slices.DeleteFunc(m.store.certifications, func(c data.Certification) bool {
+		return c.MovieID == movieID && c.Country == country
+	})
+	if len(m.store.certifications) == before {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MemoryCertificationModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.Certification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	byMovie := make(map[int64][]data.Certification, len(movieIDs))
+	for _, certification := range m.store.certifications {
+		if slices.Contains(movieIDs, certification.MovieID) {
+			byMovie[certification.MovieID] = append(byMovie[certification.MovieID], certification)
+		}
+	}
+	m.store.mu.RUnlock()
+
+	for _, certifications := range byMovie {
+		slices.SortFunc(certifications, func(a, b data.Certification) int { return strings.Compare(a.Country, b.Country) })
+	}
+	return byMovie, nil
+}
+
+// MemoryExternalIDModel is the in-memory [ExternalIDRepository], an ID belongs to a single movie of its
+// source as with the unique constraint of the table
+type MemoryExternalIDModel struct {
+	store *memoryStore
+}
+
+func (m MemoryExternalIDModel) Put(ctx context.Context, externalID *data.ExternalID) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if !m.store.hasLiveMovie(externalID.MovieID) {
+		return ErrRecordNotFound
+	}
+	i := -1
+	for j, stored := range m.store.externalIDs {
+		switch {
+		case stored.Source != externalID.Source:
+		case stored.MovieID == externalID.MovieID:
+			i = j
+		case stored.ID == externalID.ID:
+			return ErrDuplicateExternalID
+		}
+	}
+	if i < 0 {
+		m.store.externalIDs = append(m.store.externalIDs, *externalID)
+	} else {
+		m.store.externalIDs[i] = *externalID
+	}
+	return nil
+}
+
+func (m MemoryExternalIDModel) Delete(ctx context.Context, movieID int64, source string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	before := len(m.store.externalIDs)
+	m.store.externalIDs = slices.DeleteFunc(m.store.externalIDs, func(e data.ExternalID) bool {
+		return e.MovieID == movieID && e.Source == source
+	})
+	if len(m.store.externalIDs) == before {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetMovieID returns the ID of the movie which has the external ID, the ID of a merged movie resolves to
+// the movie it was merged into as with [ExternalIDModel.GetMovieID]
+func (m MemoryExternalIDModel) GetMovieID(ctx context.Context, source, externalID string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, stored := range m.store.externalIDs {
+		if stored.Source != source || stored.ID != externalID {
+			continue
+		}
+		movie, ok := m.store.movies[stored.MovieID]
+		switch {
+		case !ok:
+		case !movie.deleted:
+			return stored.MovieID, nil
+		case m.store.hasLiveMovie(movie.mergedInto):
+			return movie.mergedInto, nil
+		}
+		// An ID belongs to a single movie of its source
+		return 0, ErrRecordNotFound
+	}
+	return 0, ErrRecordNotFound
+}
+
+func (m MemoryExternalIDModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64]map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	byMovie := make(map[int64]map[string]string, len(movieIDs))
+	for _, externalID := range m.store.externalIDs {
+		if !slices.Contains(movieIDs, externalID.MovieID) {
+			continue
+		}
+		if byMovie[externalID.MovieID] == nil {
+			byMovie[externalID.MovieID] = make(map[string]string, len(data.ExternalSources))
+		}
+		byMovie[externalID.MovieID][externalID.Source] = externalID.ID
+	}
+	return byMovie, nil
+}
+
+// MemoryLocalizationModel is the in-memory [LocalizationRepository]
+type MemoryLocalizationModel struct {
+	store *memoryStore
+}
+
+func (m MemoryLocalizationModel) PutTitle(ctx context.Context, title *data.LocalizedTitle) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if !m.store.hasMovie(title.MovieID) {
+		return ErrRecordNotFound
+	}
+	i := slices.IndexFunc(m.store.titles, func(t data.LocalizedTitle) bool {
+		return t.MovieID == title.MovieID && t.Locale == title.Locale
+	})
+	if i < 0 {
+		m.store.titles = append(m.store.titles, *title)
+	} else {
+		m.store.titles[i] = *title
+	}
+	return nil
+}
+
+func (m MemoryLocalizationModel) DeleteTitle(ctx context.Context, movieID int64, locale string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	before := len(m.store.titles)
+	m.store.titles = slices.DeleteFunc(m.store.titles, func(t data.LocalizedTitle) bool {
+		return t.MovieID == movieID && t.Locale == locale
+	})
+	if len(m.store.titles) == before {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MemoryLocalizationModel) PutReleaseDate(ctx context.Context, releaseDate *data.ReleaseDate) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if !m.store.hasMovie(releaseDate.MovieID) {
+		return ErrRecordNotFound
+	}
+	i := slices.IndexFunc(m.store.releaseDates, func(r data.ReleaseDate) bool {
+		return r.MovieID == releaseDate.MovieID && r.Country == releaseDate.Country
+	})
+	if i < 0 {
+		m.store.releaseDates = append(m.store.releaseDates, *releaseDate)
+	} else {
+		m.store.releaseDates[i] = *releaseDate
+	}
+	return nil
+}
+
+func (m MemoryLocalizationModel) DeleteReleaseDate(ctx context.Context, movieID int64, country string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	before := len(m.store.releaseDates)
+	m.store.releaseDates = slices.DeleteFunc(m.store.releaseDates, func(r data.ReleaseDate) bool {
+		return r.MovieID == movieID && r.Country == country
+	})
+	if len(m.store.releaseDates) == before {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MemoryLocalizationModel) GetAllForMovie(ctx context.Context, movieID int64) ([]data.LocalizedTitle, []data.ReleaseDate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	titles := make([]data.LocalizedTitle, 0)
+	for _, title := range m.store.titles {
+		if title.MovieID == movieID {
+			titles = append(titles, title)
+		}
+	}
+	releaseDates := make([]data.ReleaseDate, 0)
+	for _, releaseDate := range m.store.releaseDates {
+		if releaseDate.MovieID == movieID {
+			releaseDates = append(releaseDates, releaseDate)
+		}
+	}
+	m.store.mu.RUnlock()
+
+	slices.SortFunc(titles, func(a, b data.LocalizedTitle) int { return strings.Compare(a.Locale, b.Locale) })
+	slices.SortFunc(releaseDates, func(a, b data.ReleaseDate) int { return strings.Compare(a.Country, b.Country) })
+	return titles, releaseDates, nil
+}
+
+// Localize returns the localization of every given movie for the locale, see [LocalizationModel.Localize]
+func (m MemoryLocalizationModel) Localize(ctx context.Context, movieIDs []int64, locale data.Locale) (map[int64]data.Localization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	localizations := make(map[int64]data.Localization, len(movieIDs))
+	for _, id := range movieIDs {
+		if !m.store.hasMovie(id) {
+			continue
+		}
+		var localization data.Localization
+		// The title of the first language with one
+	languages:
+		for _, language := range locale.Languages {
+			for _, title := range m.store.titles {
+				if title.MovieID == id && title.Locale == language {
+					localization.Title = title.Title
+					break languages
+				}
+			}
+		}
+		for _, releaseDate := range m.store.releaseDates {
+			if releaseDate.MovieID == id && releaseDate.Country == locale.Country {
+				date := releaseDate.Date
+				localization.ReleaseDate = &date
+			}
+		}
+		if localization.Title != "" || localization.ReleaseDate != nil {
+			localizations[id] = localization
+		}
+	}
+	return localizations, nil
+}
+
+// MemoryCollectionModel is the in-memory [CollectionRepository], the positions of the movies of a collection
+// always run from 1 to its number of movies as with [orderedMovies]
+type MemoryCollectionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryCollectionModel) Create(ctx context.Context, collection *data.Collection) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.lastCollectionID++
+	collection.ID = m.store.lastCollectionID
+	collection.CreatedAt = time.Now().Truncate(time.Second)
+	collection.Version = 1
+
+	stored := copyCollection(*collection)
+	stored.Artwork = nil
+	m.store.collections[collection.ID] = &stored
+	return nil
+}
+
+func (m MemoryCollectionModel) Get(ctx context.Context, id int64) (*data.Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, ok := m.store.collections[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	collection := copyCollection(*stored)
+	return &collection, nil
+}
+
+func (m MemoryCollectionModel) GetAll(ctx context.Context, name string, filters data.Filters) ([]data.Collection, data.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, data.Metadata{}, contextError(err)
+	}
+
+	sortColumns, err := filters.SortColumns()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	nameWords := words(name)
+
+	m.store.mu.RLock()
+	matching := make([]data.Collection, 0)
+	for _, stored := range m.store.collections {
+		if containsAll(words(stored.Name), nameWords) {
+			matching = append(matching, copyCollection(*stored))
+		}
+	}
+	m.store.mu.RUnlock()
+
+	var sortErr error
+	slices.SortFunc(matching, func(a, b data.Collection) int {
+		for _, column := range sortColumns {
+			var c int
+			switch column.Column {
+			case "id":
+				c = cmp.Compare(a.ID, b.ID)
+			case "name":
+				c = strings.Compare(a.Name, b.Name)
+			default:
+				sortErr = fmt.Errorf("%w: %s", data.ErrInvalidSort, column.Column)
+				return 0
+			}
+			if column.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if sortErr != nil {
+		return nil, data.Metadata{}, sortErr
+	}
+
+	totalRecords := len(matching)
+	start := min(filters.Offset(), totalRecords)
+	end := min(start+filters.Limit(), totalRecords)
+	if start == end {
+		// COUNT(*) OVER() has no row to be read from when the page is empty
+		totalRecords = 0
+	}
+	return matching[start:end], data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryCollectionModel) Update(ctx context.Context, collection *data.Collection) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.collections[collection.ID]
+	if !ok || stored.Version != collection.Version {
+		return ErrEditConflict
+	}
+	stored.Name = collection.Name
+	stored.Description = collection.Description
+	stored.Version++
+	collection.Version = stored.Version
+	return nil
+}
+
+// Delete removes the collection and its movies and returns it, so that the caller can remove its artwork files
+func (m MemoryCollectionModel) Delete(ctx context.Context, id int64) (*data.Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.collections[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	delete(m.store.collections, id)
+	m.store.collectionMovies = slices.DeleteFunc(m.store.collectionMovies, func(member data.CollectionMember) bool {
+		return member.CollectionID == id
+	})
+	return stored, nil
+}
+
+// SetArtwork replaces the artwork of the collection and returns the previous one, see [CollectionModel.SetArtwork]
+func (m MemoryCollectionModel) SetArtwork(ctx context.Context, id int64, artwork *data.StoredImage) (*data.StoredImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.collections[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	previous := stored.Artwork
+	stored.Artwork = nil
+	if artwork != nil {
+		copied := copyStoredImage(*artwork)
+		copied.URL, copied.Thumbnails = "", nil
+		stored.Artwork = &copied
+	}
+	stored.Version++
+	return previous, nil
+}
+
+// GetMembers returns the movies of the collection in order, but for the movies rated above maxRating
+func (m MemoryCollectionModel) GetMembers(ctx context.Context, collectionID int64, maxRating *data.Certification) ([]data.CollectionMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	members := make([]data.CollectionMember, 0)
+	for _, member := range m.store.collectionMovies {
+		if member.CollectionID != collectionID || !m.store.hasLiveMovie(member.MovieID) || !m.store.allows(member.MovieID, maxRating) {
+			continue
+		}
+		movie := m.store.movies[member.MovieID].movie
+		member.MovieTitle, member.MovieYear = movie.Title, movie.Year
+		members = append(members, member)
+	}
+	m.store.mu.RUnlock()
+
+	slices.SortFunc(members, func(a, b data.CollectionMember) int { return cmp.Compare(a.Position, b.Position) })
+	return members, nil
+}
+
+func (m MemoryCollectionModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.MovieCollection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	byMovie := make(map[int64][]data.MovieCollection, len(movieIDs))
+	for _, member := range m.store.collectionMovies {
+		if !slices.Contains(movieIDs, member.MovieID) {
+			continue
+		}
+		collection := m.store.collections[member.CollectionID]
+		byMovie[member.MovieID] = append(byMovie[member.MovieID], data.MovieCollection{ID: collection.ID, Name: collection.Name, Position: member.Position})
+	}
+	m.store.mu.RUnlock()
+
+	for _, collections := range byMovie {
+		slices.SortFunc(collections, func(a, b data.MovieCollection) int {
+			return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+		})
+	}
+	return byMovie, nil
+}
+
+// collectionSize returns the number of movies of the collection, [ErrRecordNotFound] when the collection
+// does not exist. The caller must hold the lock.
+func (s *memoryStore) collectionSize(collectionID int64) (int32, error) {
+	if _, ok := s.collections[collectionID]; !ok {
+		return 0, ErrRecordNotFound
+	}
+	var size int32
+	for _, member := range s.collectionMovies {
+		if member.CollectionID == collectionID {
+			size++
+		}
+	}
+	return size, nil
+}
+
+// collectionMember returns the index of the movie in the members of the collection, -1 if it is not a
+// member. The caller must hold the lock.
+func (s *memoryStore) collectionMember(collectionID, movieID int64) int {
+	return slices.IndexFunc(s.collectionMovies, func(member data.CollectionMember) bool {
+		return member.CollectionID == collectionID && member.MovieID == movieID
+	})
+}
+
+// removeCollectionMember deletes the member at index i and closes the gap in the positions of its
+// collection. The caller must hold the lock.
+func (s *memoryStore) removeCollectionMember(i int) {
+	removed := s.collectionMovies[i]
+	s.collectionMovies = slices.Delete(s.collectionMovies, i, i+1)
+	for j := range s.collectionMovies {
+		if member := &s.collectionMovies[j]; member.CollectionID == removed.CollectionID && member.Position > removed.Position {
+			member.Position--
+		}
+	}
+}
+
+// AddMovie inserts the movie in the collection at its position, shifting the following members down.
+// A position of 0 or past the end appends the movie.
+func (m MemoryCollectionModel) AddMovie(ctx context.Context, member *data.CollectionMember) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	size, err := m.store.collectionSize(member.CollectionID)
+	if err != nil {
+		return err
+	}
+	if !m.store.hasLiveMovie(member.MovieID) {
+		return ErrRecordNotFound
+	}
+	if m.store.collectionMember(member.CollectionID, member.MovieID) >= 0 {
+		return ErrDuplicateCollectionMovie
+	}
+	if member.Position == 0 || member.Position > size+1 {
+		member.Position = size + 1
+	}
+
+	for i := range m.store.collectionMovies {
+		if other := &m.store.collectionMovies[i]; other.CollectionID == member.CollectionID && other.Position >= member.Position {
+			other.Position++
+		}
+	}
+	m.store.collectionMovies = append(m.store.collectionMovies, data.CollectionMember{
+		CollectionID: member.CollectionID,
+		MovieID:      member.MovieID,
+		Position:     member.Position,
+	})
+	return nil
+}
+
+// MoveMovie moves the movie to its new position in the collection, shifting the members in between
+func (m MemoryCollectionModel) MoveMovie(ctx context.Context, member *data.CollectionMember) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	size, err := m.store.collectionSize(member.CollectionID)
+	if err != nil {
+		return err
+	}
+	if member.Position == 0 || member.Position > size {
+		member.Position = size
+	}
+	i := m.store.collectionMember(member.CollectionID, member.MovieID)
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+
+	// Moving a movie up pushes the movies in between down and vice versa
+	previousPosition := m.store.collectionMovies[i].Position
+	for j := range m.store.collectionMovies {
+		other := &m.store.collectionMovies[j]
+		if other.CollectionID != member.CollectionID || j == i {
+			continue
+		}
+		switch {
+		case member.Position < previousPosition && other.Position >= member.Position && other.Position < previousPosition:
+			other.Position++
+		case member.Position > previousPosition && other.Position <= member.Position && other.Position > previousPosition:
+			other.Position--
+		}
+	}
+	m.store.collectionMovies[i].Position = member.Position
+	return nil
+}
+
+// RemoveMovie deletes the movie from the collection and closes the gap in the positions
+func (m MemoryCollectionModel) RemoveMovie(ctx context.Context, collectionID, movieID int64) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, err := m.store.collectionSize(collectionID); err != nil {
+		return err
+	}
+	i := m.store.collectionMember(collectionID, movieID)
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+	m.store.removeCollectionMember(i)
+	return nil
+}
+
+// MemoryRecommendationModel is the in-memory [RecommendationRepository]. There are no reviews nor watch
+// history in memory to compute similarities from, so no movie is ever similar nor recommended.
+type MemoryRecommendationModel struct {
+	store *memoryStore
+}
+
+func (m MemoryRecommendationModel) RefreshSimilarities(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	return nil
+}
+
+func (m MemoryRecommendationModel) GetSimilar(ctx context.Context, movieID int64, limit int, maxRating *data.Certification) ([]data.ScoredMovie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+	return []data.ScoredMovie{}, nil
+}
+
+func (m MemoryRecommendationModel) GetForUser(ctx context.Context, userID int64, limit int, maxRating *data.Certification) ([]data.ScoredMovie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+	return []data.ScoredMovie{}, nil
+}
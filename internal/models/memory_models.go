@@ -0,0 +1,841 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+// memoryPermissionCodes holds the permissions seeded by the migrations, granting any other code is a no-op
+// just like inserting it with [PermissionModel.AddForUser]
+var memoryPermissionCodes = []string{"movies:read", "movies:write", "genres:write", "movies:admin"}
+
+// memoryStore holds the data of the in-memory backend. Every repository shares the same store so that,
+// as with the database, tokens can be joined to their user. A single lock guards all of it, see
+// [memoryStore.withTx] for the transactions.
+type memoryStore struct {
+	mu rwLocker
+	*memoryData
+}
+
+// memoryData holds the rows of the in-memory backend, the slices are tables without any particular order
+type memoryData struct {
+	movies      map[int64]*memoryMovie
+	lastMovieID int64
+	users       map[int64]*data.User
+	lastUserID  int64
+	tokens      []data.Token
+	permissions map[int64][]string
+
+	genres           map[int64]*data.Genre
+	lastGenreID      int64
+	images           []data.MovieImage
+	lastImageID      int64
+	certifications   []data.Certification
+	externalIDs      []data.ExternalID
+	titles           []data.LocalizedTitle
+	releaseDates     []data.ReleaseDate
+	collections      map[int64]*data.Collection
+	lastCollectionID int64
+	collectionMovies []data.CollectionMember // Only the collection, the movie and the position are set
+}
+
+type memoryMovie struct {
+	movie      data.Movie
+	deleted    bool
+	mergedInto int64
+}
+
+// rwLocker is the lock of a [memoryStore], a sync.RWMutex or noLock within a transaction
+type rwLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// noLock is the lock of the store given to a transaction, which already holds the lock of the store
+type noLock struct{}
+
+func (noLock) Lock()    {}
+func (noLock) Unlock()  {}
+func (noLock) RLock()   {}
+func (noLock) RUnlock() {}
+
+// NewMemory returns the models of the in-memory backend, the people, credits, reviews, watch history and
+// lists need PostgreSQL. The genres are seeded like the migrations do. The data is lost when the process
+// exits, it is meant for tests and local development.
+func NewMemory() Models {
+	store := &memoryStore{
+		mu: &sync.RWMutex{},
+		memoryData: &memoryData{
+			movies:      make(map[int64]*memoryMovie),
+			users:       make(map[int64]*data.User),
+			permissions: make(map[int64][]string),
+			genres:      make(map[int64]*data.Genre),
+			collections: make(map[int64]*data.Collection),
+		},
+	}
+	now := time.Now().Truncate(time.Second)
+	for _, seed := range data.SeedGenres {
+		store.lastGenreID++
+		genre := copyGenre(seed)
+		genre.ID, genre.CreatedAt, genre.Version = store.lastGenreID, now, 1
+		store.genres[genre.ID] = &genre
+	}
+	return newMemoryModels(store)
+}
+
+// newMemoryModels returns the models of the in-memory backend reading and writing the store
+func newMemoryModels(store *memoryStore) Models {
+	return Models{
+		Movie:          MemoryMovieModel{store: store},
+		User:           MemoryUserModel{store: store},
+		Token:          MemoryTokenModel{store: store},
+		Permission:     MemoryPermissionModel{store: store},
+		Recommendation: MemoryRecommendationModel{store: store},
+		Genre:          MemoryGenreModel{store: store},
+		Image:          MemoryImageModel{store: store},
+		Localization:   MemoryLocalizationModel{store: store},
+		Certification:  MemoryCertificationModel{store: store},
+		ExternalID:     MemoryExternalIDModel{store: store},
+		Collection:     MemoryCollectionModel{store: store},
+		memory:         store,
+	}
+}
+
+// withTx runs fn with models holding the lock of the store until it returns, so that no other change is
+// interleaved with the ones of fn. They are undone when fn returns an error. The models of fn must not be
+// mixed with the ones of the store, which would wait for the lock held by fn.
+func (s *memoryStore) withTx(fn func(tx Models) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.memoryData.clone()
+	if err := fn(newMemoryModels(&memoryStore{mu: noLock{}, memoryData: s.memoryData})); err != nil {
+		*s.memoryData = *snapshot
+		return err
+	}
+	return nil
+}
+
+// clone returns a copy of the data which doesn't share anything with it
+func (d *memoryData) clone() *memoryData {
+	c := *d
+	c.movies = make(map[int64]*memoryMovie, len(d.movies))
+	for id, stored := range d.movies {
+		movie := *stored
+		movie.movie = copyMovie(stored.movie)
+		c.movies[id] = &movie
+	}
+	c.users = make(map[int64]*data.User, len(d.users))
+	for id, stored := range d.users {
+		user := copyUser(*stored)
+		c.users[id] = &user
+	}
+	c.tokens = make([]data.Token, 0, len(d.tokens))
+	for _, token := range d.tokens {
+		token.Hash = slices.Clone(token.Hash)
+		c.tokens = append(c.tokens, token)
+	}
+	c.permissions = make(map[int64][]string, len(d.permissions))
+	for id, codes := range d.permissions {
+		c.permissions[id] = slices.Clone(codes)
+	}
+
+	c.genres = make(map[int64]*data.Genre, len(d.genres))
+	for id, stored := range d.genres {
+		genre := copyGenre(*stored)
+		c.genres[id] = &genre
+	}
+	c.images = make([]data.MovieImage, 0, len(d.images))
+	for _, image := range d.images {
+		image.StoredImage = copyStoredImage(image.StoredImage)
+		c.images = append(c.images, image)
+	}
+	c.certifications = slices.Clone(d.certifications)
+	c.externalIDs = slices.Clone(d.externalIDs)
+	c.titles = slices.Clone(d.titles)
+	c.releaseDates = slices.Clone(d.releaseDates)
+	c.collections = make(map[int64]*data.Collection, len(d.collections))
+	for id, stored := range d.collections {
+		collection := copyCollection(*stored)
+		c.collections[id] = &collection
+	}
+	c.collectionMovies = slices.Clone(d.collectionMovies)
+	return &c
+}
+
+// copyMovie returns a copy of the movie which doesn't share its genres with the stored one
+func copyMovie(movie data.Movie) data.Movie {
+	movie.Genres = slices.Clone(movie.Genres)
+	return movie
+}
+
+// copyUser returns a copy of the user which doesn't share its password hash nor rating limit with the stored one
+func copyUser(user data.User) data.User {
+	user.Password = data.Password{Hash: slices.Clone(user.Password.Hash)}
+	if user.MaxRating != nil {
+		maxRating := *user.MaxRating
+		user.MaxRating = &maxRating
+	}
+	return user
+}
+
+// MemoryMovieModel is the in-memory [MovieRepository]. The movies have no credits, so filtering them by
+// person behaves as it would in a database without those rows.
+type MemoryMovieModel struct {
+	store *memoryStore
+}
+
+func (m MemoryMovieModel) Create(ctx context.Context, movie *data.Movie) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.lastMovieID++
+	movie.ID = m.store.lastMovieID
+	// Like the timestamp(0) column, the creation time is stored with a precision of one second
+	movie.CreatedAt = time.Now().Truncate(time.Second)
+	movie.Version = 1
+	movie.AverageRating = 0
+	movie.RatingCount = 0
+
+	m.store.movies[movie.ID] = &memoryMovie{movie: copyMovie(*movie)}
+	return nil
+}
+
+func (m MemoryMovieModel) Get(ctx context.Context, id int64) (*data.Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, ok := m.store.movies[id]
+	if !ok || stored.deleted {
+		return nil, ErrRecordNotFound
+	}
+	movie := copyMovie(stored.movie)
+	return &movie, nil
+}
+
+func (m MemoryMovieModel) GetAll(ctx context.Context, movieFilters data.MovieFilters, filters data.Filters) ([]data.Movie, data.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, data.Metadata{}, contextError(err)
+	}
+
+	sortColumns, err := filters.SortColumns()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	m.store.mu.RLock()
+	matching := m.store.filter(movieFilters)
+	m.store.mu.RUnlock()
+
+	var sortErr error
+	slices.SortFunc(matching, func(a, b data.Movie) int {
+		for _, column := range sortColumns {
+			c, err := compareMovies(a, b, column.Column)
+			if err != nil {
+				sortErr = err
+				return 0
+			}
+			if column.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if sortErr != nil {
+		return nil, data.Metadata{}, sortErr
+	}
+
+	totalRecords := len(matching)
+	start := min(filters.Offset(), totalRecords)
+	end := min(start+filters.Limit(), totalRecords)
+	movies := make([]data.Movie, 0, end-start)
+	movies = append(movies, matching[start:end]...)
+
+	if len(movies) == 0 {
+		// COUNT(*) OVER() has no row to be read from when the page is empty
+		totalRecords = 0
+	}
+	return movies, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// compareMovies compares a column of the movie sort safe list
+func compareMovies(a, b data.Movie, column string) (int, error) {
+	switch column {
+	case "id":
+		return cmp.Compare(a.ID, b.ID), nil
+	case "title":
+		return strings.Compare(a.Title, b.Title), nil
+	case "year":
+		return cmp.Compare(a.Year, b.Year), nil
+	case "runtime":
+		return cmp.Compare(a.Runtime, b.Runtime), nil
+	case "average_rating":
+		return cmp.Compare(a.AverageRating, b.AverageRating), nil
+	case "rating_count":
+		return cmp.Compare(a.RatingCount, b.RatingCount), nil
+	default:
+		return 0, fmt.Errorf("%w: %s", data.ErrInvalidSort, column)
+	}
+}
+
+// filter returns a copy of every live movie matching the filters, the caller must hold the lock
+func (s *memoryStore) filter(f data.MovieFilters) []data.Movie {
+	var titleWords []string
+	if f.Title != "" {
+		titleWords = words(f.Title)
+	}
+
+	movies := make([]data.Movie, 0)
+	for _, stored := range s.movies {
+		movie := stored.movie
+		switch {
+		case stored.deleted:
+		case f.Title != "" && !s.titleMatches(movie, titleWords):
+		case !containsAll(movie.Genres, f.Genres):
+		case len(f.GenresAny) > 0 && !slices.ContainsFunc(f.GenresAny, func(g string) bool { return slices.Contains(movie.Genres, g) }):
+		case slices.ContainsFunc(f.GenresNone, func(g string) bool { return slices.Contains(movie.Genres, g) }):
+		case f.YearMin != 0 && int(movie.Year) < f.YearMin:
+		case f.YearMax != 0 && int(movie.Year) > f.YearMax:
+		case f.RuntimeMin != 0 && int(movie.Runtime) < f.RuntimeMin:
+		case f.RuntimeMax != 0 && int(movie.Runtime) > f.RuntimeMax:
+		case !f.CreatedAfter.IsZero() && !movie.CreatedAt.After(f.CreatedAfter):
+		// There are no credits to match, see [movieFilterCondition]
+		case f.PersonID != 0:
+		case f.CollectionID != 0 && s.collectionMember(f.CollectionID, movie.ID) < 0:
+		case !s.allows(movie.ID, f.MaxRating):
+		default:
+			movies = append(movies, copyMovie(movie))
+		}
+	}
+	return movies
+}
+
+// titleMatches reports whether the title of the movie, or one of its localized titles, has every word.
+// The caller must hold the lock.
+func (s *memoryStore) titleMatches(movie data.Movie, titleWords []string) bool {
+	if containsAll(words(movie.Title), titleWords) {
+		return true
+	}
+	return slices.ContainsFunc(s.titles, func(title data.LocalizedTitle) bool {
+		return title.MovieID == movie.ID && containsAll(words(title.Title), titleWords)
+	})
+}
+
+var (
+	nonAlnumRX       = regexp.MustCompile(`[^\pL\pN]+`)
+	leadingArticleRX = regexp.MustCompile(`^(the|a|an)\s+`)
+)
+
+// words splits the text into lower cased words, as the 'simple' text search configuration does
+func words(text string) []string {
+	return strings.Fields(nonAlnumRX.ReplaceAllString(strings.ToLower(text), " "))
+}
+
+// containsAll reports whether s contains every value of values
+func containsAll(s, values []string) bool {
+	for _, value := range values {
+		if !slices.Contains(s, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m MemoryMovieModel) GetFacets(ctx context.Context, movieFilters data.MovieFilters, facets []string) (data.Facets, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	matching := m.store.filter(movieFilters)
+	m.store.mu.RUnlock()
+
+	result := make(data.Facets, len(facets))
+	for _, facet := range facets {
+		counts := make(map[string]int)
+		var buckets []data.FacetBucket
+		switch facet {
+		case data.FacetGenres:
+			for _, movie := range matching {
+				for _, genre := range movie.Genres {
+					counts[genre]++
+				}
+			}
+			buckets = facetBuckets(counts)
+			slices.SortFunc(buckets, func(a, b data.FacetBucket) int {
+				return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Value, b.Value))
+			})
+		case data.FacetDecade:
+			for _, movie := range matching {
+				counts[strconv.Itoa(int(movie.Year/10*10))+"s"]++
+			}
+			buckets = facetBuckets(counts)
+			slices.SortFunc(buckets, func(a, b data.FacetBucket) int {
+				// "1990s" sorts before "2000s" as long as years have 4 digits, compare the numbers anyway
+				ya, _ := strconv.Atoi(strings.TrimSuffix(a.Value, "s"))
+				yb, _ := strconv.Atoi(strings.TrimSuffix(b.Value, "s"))
+				return cmp.Compare(ya, yb)
+			})
+		default:
+			return nil, fmt.Errorf("unsupported facet: %s", facet)
+		}
+		result[facet] = buckets
+	}
+	return result, nil
+}
+
+func facetBuckets(counts map[string]int) []data.FacetBucket {
+	buckets := make([]data.FacetBucket, 0, len(counts))
+	for value, count := range counts {
+		buckets = append(buckets, data.FacetBucket{Value: value, Count: count})
+	}
+	return buckets
+}
+
+func (m MemoryMovieModel) Update(ctx context.Context, movie *data.Movie) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.movies[movie.ID]
+	if !ok || stored.deleted || stored.movie.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	stored.movie.Title = movie.Title
+	stored.movie.Year = movie.Year
+	stored.movie.Runtime = movie.Runtime
+	stored.movie.Genres = slices.Clone(movie.Genres)
+	stored.movie.Version++
+	movie.Version = stored.movie.Version
+	return nil
+}
+
+// Delete removes the movie and the rows referencing it, closing the gaps it leaves in the positions of its
+// collections as with [MovieModel.Delete]
+func (m MemoryMovieModel) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.movies[id]
+	if !ok || stored.deleted {
+		return ErrRecordNotFound
+	}
+	for i := m.store.movieMember(id); i >= 0; i = m.store.movieMember(id) {
+		m.store.removeCollectionMember(i)
+	}
+	m.store.images = slices.DeleteFunc(m.store.images, func(image data.MovieImage) bool { return image.MovieID == id })
+	m.store.certifications = slices.DeleteFunc(m.store.certifications, func(c data.Certification) bool { return c.MovieID == id })
+	m.store.externalIDs = slices.DeleteFunc(m.store.externalIDs, func(e data.ExternalID) bool { return e.MovieID == id })
+	m.store.titles = slices.DeleteFunc(m.store.titles, func(t data.LocalizedTitle) bool { return t.MovieID == id })
+	m.store.releaseDates = slices.DeleteFunc(m.store.releaseDates, func(r data.ReleaseDate) bool { return r.MovieID == id })
+	delete(m.store.movies, id)
+	return nil
+}
+
+// movieMember returns the index of a collection membership of the movie, -1 if it has none. The caller must
+// hold the lock.
+func (s *memoryStore) movieMember(movieID int64) int {
+	return slices.IndexFunc(s.collectionMovies, func(member data.CollectionMember) bool { return member.MovieID == movieID })
+}
+
+// normalizeTitle mirrors the [normalizedTitle] SQL expression
+func normalizeTitle(title string) string {
+	return nonAlnumRX.ReplaceAllString(leadingArticleRX.ReplaceAllString(strings.ToLower(title), ""), "")
+}
+
+func (m MemoryMovieModel) GetDuplicates(ctx context.Context, duplicateFilters data.DuplicateFilters, filters data.Filters) ([]data.DuplicateCandidate, data.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, data.Metadata{}, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	groups := make(map[string][]data.Movie)
+	for _, stored := range m.store.movies {
+		if stored.deleted {
+			continue
+		}
+		if normalized := normalizeTitle(stored.movie.Title); normalized != "" {
+			groups[normalized] = append(groups[normalized], copyMovie(stored.movie))
+		}
+	}
+	m.store.mu.RUnlock()
+
+	abs := func(n int) int { return max(n, -n) }
+
+	candidates := make([]data.DuplicateCandidate, 0)
+	for normalized, movies := range groups {
+		slices.SortFunc(movies, func(a, b data.Movie) int { return cmp.Compare(a.ID, b.ID) })
+		for i, a := range movies {
+			for _, b := range movies[i+1:] {
+				if abs(int(a.Year-b.Year)) <= duplicateFilters.YearTolerance && abs(int(a.Runtime-b.Runtime)) <= duplicateFilters.RuntimeTolerance {
+					candidates = append(candidates, data.DuplicateCandidate{NormalizedTitle: normalized, Movie: a, Duplicate: b})
+				}
+			}
+		}
+	}
+	slices.SortFunc(candidates, func(a, b data.DuplicateCandidate) int {
+		return cmp.Or(
+			strings.Compare(a.NormalizedTitle, b.NormalizedTitle),
+			cmp.Compare(a.Movie.ID, b.Movie.ID),
+			cmp.Compare(a.Duplicate.ID, b.Duplicate.ID),
+		)
+	})
+
+	totalRecords := len(candidates)
+	start := min(filters.Offset(), totalRecords)
+	end := min(start+filters.Limit(), totalRecords)
+	if start == end {
+		totalRecords = 0
+	}
+	return candidates[start:end], data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Merge moves the data of the movie sourceID to the movie targetID and soft deletes the source, following
+// the [mergeStatements]: the rows which would collide with a row of the target are left on the source, but
+// for the collection memberships which are removed.
+func (m MemoryMovieModel) Merge(ctx context.Context, sourceID, targetID int64) (*data.Movie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	source, ok := m.store.movies[sourceID]
+	if !ok || source.deleted {
+		return nil, ErrRecordNotFound
+	}
+	target, ok := m.store.movies[targetID]
+	if !ok || target.deleted {
+		return nil, ErrRecordNotFound
+	}
+
+	for i := len(m.store.collectionMovies) - 1; i >= 0; i-- {
+		member := m.store.collectionMovies[i]
+		if member.MovieID == sourceID && m.store.collectionMember(member.CollectionID, targetID) >= 0 {
+			m.store.removeCollectionMember(i)
+		}
+	}
+	for i := range m.store.collectionMovies {
+		if m.store.collectionMovies[i].MovieID == sourceID {
+			m.store.collectionMovies[i].MovieID = targetID
+		}
+	}
+	for i := range m.store.images {
+		if m.store.images[i].MovieID == sourceID {
+			m.store.images[i].MovieID = targetID
+		}
+	}
+	moveRows(m.store.titles, sourceID, targetID, func(t *data.LocalizedTitle) (*int64, string) { return &t.MovieID, t.Locale })
+	moveRows(m.store.releaseDates, sourceID, targetID, func(r *data.ReleaseDate) (*int64, string) { return &r.MovieID, r.Country })
+	moveRows(m.store.certifications, sourceID, targetID, func(c *data.Certification) (*int64, string) { return &c.MovieID, c.Country })
+	moveRows(m.store.externalIDs, sourceID, targetID, func(e *data.ExternalID) (*int64, string) { return &e.MovieID, e.Source })
+
+	for _, stored := range m.store.movies {
+		if stored.mergedInto == sourceID {
+			stored.mergedInto = targetID
+		}
+	}
+	source.deleted = true
+	source.mergedInto = targetID
+	source.movie.Version++
+
+	movie := copyMovie(target.movie)
+	return &movie, nil
+}
+
+// moveRows moves the rows of the movie sourceID to the movie targetID, but for the ones whose key the target
+// already has. key returns the movie ID and the key of a row, which are unique together.
+func moveRows[T any](rows []T, sourceID, targetID int64, key func(row *T) (*int64, string)) {
+	targetKeys := make(map[string]bool)
+	for i := range rows {
+		if movieID, k := key(&rows[i]); *movieID == targetID {
+			targetKeys[k] = true
+		}
+	}
+	for i := range rows {
+		if movieID, k := key(&rows[i]); *movieID == sourceID && !targetKeys[k] {
+			*movieID = targetID
+		}
+	}
+}
+
+// MemoryUserModel is the in-memory [UserRepository], emails are unique regardless of their case as with
+// the citext column
+type MemoryUserModel struct {
+	store *memoryStore
+}
+
+// userByEmail returns the stored user with the email, the caller must hold the lock
+func (s *memoryStore) userByEmail(email string) *data.User {
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+func (m MemoryUserModel) Create(ctx context.Context, user *data.User) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.userByEmail(user.Email) != nil {
+		return fmt.Errorf("%w: Key (email)=(%s) already exists.", ErrDuplicateEmail, user.Email)
+	}
+
+	m.store.lastUserID++
+	user.ID = m.store.lastUserID
+	user.CreatedAt = time.Now().Truncate(time.Second)
+	user.Version = 1
+
+	stored := copyUser(*user)
+	m.store.users[user.ID] = &stored
+	return nil
+}
+
+func (m MemoryUserModel) GetByEmail(ctx context.Context, email string) (*data.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored := m.store.userByEmail(email)
+	if stored == nil {
+		return nil, ErrRecordNotFound
+	}
+	user := copyUser(*stored)
+	return &user, nil
+}
+
+func (m MemoryUserModel) GetUserWithToken(ctx context.Context, plainToken string, scope string) (*data.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	tokenHash := sha256.Sum256([]byte(plainToken))
+	now := time.Now()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, token := range m.store.tokens {
+		if slices.Equal(token.Hash, tokenHash[:]) && token.Scope == scope && token.Expiry.After(now) {
+			stored, ok := m.store.users[token.UserID]
+			if !ok {
+				break
+			}
+			user := copyUser(*stored)
+			return &user, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryUserModel) Update(ctx context.Context, user *data.User) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return ErrEditConflict
+	}
+	if other := m.store.userByEmail(user.Email); other != nil && other.ID != user.ID {
+		return fmt.Errorf("%w: Key (email)=(%s) already exists.", ErrDuplicateEmail, user.Email)
+	}
+
+	// The password is not part of the update, as with [UserModel.Update]
+	updated := copyUser(*user)
+	updated.Password = stored.Password
+	updated.CreatedAt = stored.CreatedAt
+	updated.Version = stored.Version + 1
+	m.store.users[user.ID] = &updated
+	user.Version = updated.Version
+	return nil
+}
+
+func (m MemoryUserModel) DeleteAllTokenForUser(ctx context.Context, scope string, userId int64) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.tokens = slices.DeleteFunc(m.store.tokens, func(token data.Token) bool {
+		return token.Scope == scope && token.UserID == userId
+	})
+	return nil
+}
+
+// MemoryTokenModel is the in-memory [TokenRepository]. Expired tokens are kept, like in the database they
+// are simply never matched again.
+type MemoryTokenModel struct {
+	store *memoryStore
+}
+
+func (m MemoryTokenModel) New(ctx context.Context, userId int64, ttl time.Duration, scope string) (*data.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	plain, hash := TokenModel{}.generateToken()
+	token := &data.Token{UserID: userId, Plain: plain, Hash: hash, Expiry: time.Now().Add(ttl), Scope: scope}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	// Mirrors the foreign key of the tokens table
+	if _, ok := m.store.users[userId]; !ok {
+		return nil, fmt.Errorf("m.create token error :%w", ErrRecordNotFound)
+	}
+	stored := *token
+	stored.Plain = ""
+	m.store.tokens = append(m.store.tokens, stored)
+	return token, nil
+}
+
+func (m MemoryTokenModel) GetByUserId(ctx context.Context, userId int64) (*data.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, token := range m.store.tokens {
+		if token.UserID == userId {
+			token.Hash = slices.Clone(token.Hash)
+			return &token, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryTokenModel) DeleteAllForUser(ctx context.Context, userId int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	before := len(m.store.tokens)
+	m.store.tokens = slices.DeleteFunc(m.store.tokens, func(token data.Token) bool {
+		return token.UserID == userId
+	})
+	return int64(before - len(m.store.tokens)), nil
+}
+
+func (m MemoryTokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	now := time.Now()
+	before := len(m.store.tokens)
+	m.store.tokens = slices.DeleteFunc(m.store.tokens, func(token data.Token) bool {
+		return !token.Expiry.After(now)
+	})
+	return int64(before - len(m.store.tokens)), nil
+}
+
+// MemoryPermissionModel is the in-memory [PermissionRepository]
+type MemoryPermissionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryPermissionModel) GetAllForUser(ctx context.Context, userId int64) (Permissions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	return slices.Clone(m.store.permissions[userId]), nil
+}
+
+func (m MemoryPermissionModel) AddForUser(ctx context.Context, userId int64, permissions ...string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.users[userId]; !ok {
+		return fmt.Errorf("user %d: %w", userId, ErrRecordNotFound)
+	}
+	granted := m.store.permissions[userId]
+	for _, code := range permissions {
+		if !slices.Contains(memoryPermissionCodes, code) {
+			continue
+		}
+		// The primary key of users_permissions rejects a permission granted twice
+		if slices.Contains(granted, code) {
+			return fmt.Errorf("permission %s already granted to user %d", code, userId)
+		}
+		granted = append(granted, code)
+	}
+	m.store.permissions[userId] = granted
+	return nil
+}
+
+func (m MemoryPermissionModel) RemoveForUser(ctx context.Context, userId int64, permissions ...string) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	m.store.permissions[userId] = slices.DeleteFunc(m.store.permissions[userId], func(code string) bool {
+		return slices.Contains(permissions, code)
+	})
+	return nil
+}
@@ -1,29 +1,202 @@
 package models
 
 import (
+	"context"
 	"errors"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict   = errors.New("edit conflict")
-	ErrDuplicateEmail = errors.New("duplicate email")
+	ErrRecordNotFound           = errors.New("record not found")
+	ErrEditConflict             = errors.New("edit conflict")
+	ErrDuplicateEmail           = errors.New("duplicate email")
+	ErrDuplicateCredit          = errors.New("duplicate credit")
+	ErrDuplicateReview          = errors.New("duplicate review")
+	ErrDuplicateListItem        = errors.New("duplicate list item")
+	ErrDuplicateGenre           = errors.New("duplicate genre")
+	ErrGenreInUse               = errors.New("genre in use")
+	ErrDuplicateExternalID      = errors.New("duplicate external id")
+	ErrDuplicateCollectionMovie = errors.New("duplicate collection movie")
+	// ErrLocked is returned by the batch jobs another instance of the API is running
+	ErrLocked = errors.New("locked by another instance")
 )
 
+// MovieRepository stores the movies, it is implemented by [MovieModel] and [MemoryMovieModel]
+type MovieRepository interface {
+	Create(ctx context.Context, movie *data.Movie) error
+	Get(ctx context.Context, id int64) (*data.Movie, error)
+	GetAll(ctx context.Context, movieFilters data.MovieFilters, filters data.Filters) ([]data.Movie, data.Metadata, error)
+	GetFacets(ctx context.Context, movieFilters data.MovieFilters, facets []string) (data.Facets, error)
+	Update(ctx context.Context, movie *data.Movie) error
+	Delete(ctx context.Context, id int64) error
+	GetDuplicates(ctx context.Context, duplicateFilters data.DuplicateFilters, filters data.Filters) ([]data.DuplicateCandidate, data.Metadata, error)
+	Merge(ctx context.Context, sourceID, targetID int64) (*data.Movie, error)
+}
+
+// UserRepository stores the users, it is implemented by [UserModel] and [MemoryUserModel]
+type UserRepository interface {
+	Create(ctx context.Context, user *data.User) error
+	GetByEmail(ctx context.Context, email string) (*data.User, error)
+	GetUserWithToken(ctx context.Context, plainToken string, scope string) (*data.User, error)
+	Update(ctx context.Context, user *data.User) error
+	DeleteAllTokenForUser(ctx context.Context, scope string, userId int64) error
+}
+
+// TokenRepository stores the tokens, it is implemented by [TokenModel] and [MemoryTokenModel]
+type TokenRepository interface {
+	New(ctx context.Context, userId int64, ttl time.Duration, scope string) (*data.Token, error)
+	GetByUserId(ctx context.Context, userId int64) (*data.Token, error)
+	DeleteAllForUser(ctx context.Context, userId int64) (int64, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PermissionRepository stores the permissions of the users, it is implemented by [PermissionModel] and
+// [MemoryPermissionModel]
+type PermissionRepository interface {
+	GetAllForUser(ctx context.Context, userId int64) (Permissions, error)
+	AddForUser(ctx context.Context, userId int64, permissions ...string) error
+	RemoveForUser(ctx context.Context, userId int64, permissions ...string) error
+}
+
+// GenreRepository stores the managed genres, it is implemented by [GenreModel] and [MemoryGenreModel]
+type GenreRepository interface {
+	Create(ctx context.Context, genre *data.Genre) error
+	GetAll(ctx context.Context) ([]data.Genre, error)
+	Index(ctx context.Context) (data.GenreIndex, error)
+	Get(ctx context.Context, id int64) (*data.Genre, error)
+	Update(ctx context.Context, genre *data.Genre, previousSlug string) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// ImageRepository stores the records of the movie images, it is implemented by [ImageModel] and
+// [MemoryImageModel]
+type ImageRepository interface {
+	Create(ctx context.Context, image *data.MovieImage) error
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.MovieImage, error)
+	Delete(ctx context.Context, movieID, imageID int64) (*data.MovieImage, error)
+}
+
+// LocalizationRepository stores the localized titles and release dates of the movies, it is implemented by
+// [LocalizationModel] and [MemoryLocalizationModel]
+type LocalizationRepository interface {
+	PutTitle(ctx context.Context, title *data.LocalizedTitle) error
+	DeleteTitle(ctx context.Context, movieID int64, locale string) error
+	PutReleaseDate(ctx context.Context, releaseDate *data.ReleaseDate) error
+	DeleteReleaseDate(ctx context.Context, movieID int64, country string) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]data.LocalizedTitle, []data.ReleaseDate, error)
+	Localize(ctx context.Context, movieIDs []int64, locale data.Locale) (map[int64]data.Localization, error)
+}
+
+// CertificationRepository stores the certifications of the movies, it is implemented by [CertificationModel]
+// and [MemoryCertificationModel]
+type CertificationRepository interface {
+	Put(ctx context.Context, certification *data.Certification) error
+	Delete(ctx context.Context, movieID int64, country string) error
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.Certification, error)
+}
+
+// ExternalIDRepository stores the IDs of the movies in external databases, it is implemented by
+// [ExternalIDModel] and [MemoryExternalIDModel]
+type ExternalIDRepository interface {
+	Put(ctx context.Context, externalID *data.ExternalID) error
+	Delete(ctx context.Context, movieID int64, source string) error
+	GetMovieID(ctx context.Context, source, externalID string) (int64, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64]map[string]string, error)
+}
+
+// CollectionRepository stores the collections and their ordered movies, it is implemented by
+// [CollectionModel] and [MemoryCollectionModel]
+type CollectionRepository interface {
+	Create(ctx context.Context, collection *data.Collection) error
+	Get(ctx context.Context, id int64) (*data.Collection, error)
+	GetAll(ctx context.Context, name string, filters data.Filters) ([]data.Collection, data.Metadata, error)
+	Update(ctx context.Context, collection *data.Collection) error
+	Delete(ctx context.Context, id int64) (*data.Collection, error)
+	SetArtwork(ctx context.Context, id int64, artwork *data.StoredImage) (*data.StoredImage, error)
+	GetMembers(ctx context.Context, collectionID int64, maxRating *data.Certification) ([]data.CollectionMember, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]data.MovieCollection, error)
+	AddMovie(ctx context.Context, member *data.CollectionMember) error
+	MoveMovie(ctx context.Context, member *data.CollectionMember) error
+	RemoveMovie(ctx context.Context, collectionID, movieID int64) error
+}
+
+// RecommendationRepository computes the similar movies and the recommendations, it is implemented by
+// [RecommendationModel] and [MemoryRecommendationModel]
+type RecommendationRepository interface {
+	RefreshSimilarities(ctx context.Context) error
+	GetSimilar(ctx context.Context, movieID int64, limit int, maxRating *data.Certification) ([]data.ScoredMovie, error)
+	GetForUser(ctx context.Context, userID int64, limit int, maxRating *data.Certification) ([]data.ScoredMovie, error)
+}
+
+// Models gathers the models of every resource. With the in-memory backend (see [NewMemory]) only the
+// repositories are set, the people, credits, reviews, lists and watch history need PostgreSQL.
 type Models struct {
-	Movie      MovieModel
-	User       UserModel
-	Token      TokenModel
-	Permission PermissionModel
+	Movie          MovieRepository
+	User           UserRepository
+	Token          TokenRepository
+	Permission     PermissionRepository
+	Person         PersonModel
+	Credit         CreditModel
+	Review         ReviewModel
+	List           ListModel
+	History        HistoryModel
+	Recommendation RecommendationRepository
+	Genre          GenreRepository
+	Image          ImageRepository
+	Localization   LocalizationRepository
+	Certification  CertificationRepository
+	ExternalID     ExternalIDRepository
+	Collection     CollectionRepository
+
+	db     *DB          // nil with the in-memory backend
+	memory *memoryStore // nil with PostgreSQL
 }
 
-func New(db *pgxpool.Pool) Models {
+// New returns the models running their queries on db
+func New(db *DB) Models {
 	return Models{
-		Movie:      MovieModel{DB: db},
-		User:       UserModel{DB: db},
-		Token:      TokenModel{DB: db},
-		Permission: PermissionModel{DB: db},
+		Movie:          MovieModel{DB: db},
+		User:           UserModel{DB: db},
+		Token:          TokenModel{DB: db},
+		Permission:     PermissionModel{DB: db},
+		Person:         PersonModel{DB: db},
+		Credit:         CreditModel{DB: db},
+		Review:         ReviewModel{DB: db},
+		List:           ListModel{DB: db},
+		History:        HistoryModel{DB: db},
+		Recommendation: RecommendationModel{DB: db},
+		Genre:          GenreModel{DB: db},
+		Image:          ImageModel{DB: db},
+		Localization:   LocalizationModel{DB: db},
+		Certification:  CertificationModel{DB: db},
+		ExternalID:     ExternalIDModel{DB: db},
+		Collection:     CollectionModel{DB: db},
+		db:             db,
+	}
+}
+
+// WithTx runs fn in a single transaction: the models given to fn run their queries in it. The transaction
+// is committed when fn returns nil, and rolled back when it returns an error which is then returned as is.
+// With the in-memory backend, fn holds the lock of the store and its changes are undone on error.
+func (m Models) WithTx(ctx context.Context, fn func(tx Models) error) error {
+	if m.memory != nil {
+		if err := ctx.Err(); err != nil {
+			return contextError(err)
+		}
+		return m.memory.withTx(fn)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := fn(New(&DB{conn: tx, QueryTimeout: m.db.QueryTimeout})); err != nil {
+		return err
 	}
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+// normalizedTitle is the SQL expression duplicates are grouped by: the title lower cased, without a leading
+// article and without anything but letters and digits, e.g. "The Lord of the Rings: Return" and
+// "Lord of the rings - return" are both "lordoftheringsreturn".
+const normalizedTitle = `regexp_replace(regexp_replace(lower(title), '^(the|a|an)\s+', ''), '[^[:alnum:]]+', '', 'g')`
+
+// GetDuplicates returns a page of the pairs of movies sharing the same normalized title whose year and
+// runtime are within the tolerances
+func (m MovieModel) GetDuplicates(ctx context.Context, duplicateFilters data.DuplicateFilters, filters data.Filters) ([]data.DuplicateCandidate, data.Metadata, error) {
+	query := `
+	WITH normalized AS (
+		SELECT id, created_at, title, year, runtime, genres, version, average_rating, rating_count,
+			` + normalizedTitle + ` AS normalized_title
+		FROM movies
+		WHERE deleted_at IS NULL
+	)
+	SELECT COUNT(*) OVER() AS count, a.normalized_title,
+		a.id, a.created_at, a.title, a.year, a.runtime, a.genres, a.version, a.average_rating, a.rating_count,
+		b.id, b.created_at, b.title, b.year, b.runtime, b.genres, b.version, b.average_rating, b.rating_count
+	FROM normalized a
+	INNER JOIN normalized b ON a.normalized_title = b.normalized_title AND a.id < b.id
+	WHERE a.normalized_title <> ''
+		AND abs(a.year - b.year) <= $1
+		AND abs(a.runtime - b.runtime) <= $2
+	ORDER BY a.normalized_title, a.id, b.id
+	LIMIT $3 OFFSET $4;
+	`
+	args := []any{duplicateFilters.YearTolerance, duplicateFilters.RuntimeTolerance, filters.Limit(), filters.Offset()}
+
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, data.Metadata{}, fmt.Errorf("error when Query in GetDuplicates %w", err)
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	candidates := make([]data.DuplicateCandidate, 0)
+	for rows.Next() {
+		var c data.DuplicateCandidate
+		err := rows.Scan(&totalRecords, &c.NormalizedTitle,
+			&c.Movie.ID, &c.Movie.CreatedAt, &c.Movie.Title, &c.Movie.Year, &c.Movie.Runtime, &c.Movie.Genres,
+			&c.Movie.Version, &c.Movie.AverageRating, &c.Movie.RatingCount,
+			&c.Duplicate.ID, &c.Duplicate.CreatedAt, &c.Duplicate.Title, &c.Duplicate.Year, &c.Duplicate.Runtime, &c.Duplicate.Genres,
+			&c.Duplicate.Version, &c.Duplicate.AverageRating, &c.Duplicate.RatingCount,
+		)
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	return candidates, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// mergeStatements move the data of the movie @source to the movie @target. Rows which would collide with
+// a row of the target (e.g. a user who reviewed both movies) are left on the source movie, except for the
+// list items and collection members which are removed so that the merged movie does not show up twice.
+var mergeStatements = []string{
+	`UPDATE reviews SET movie_id = @target
+	WHERE movie_id = @source AND user_id NOT IN (SELECT user_id FROM reviews WHERE movie_id = @target)`,
+
+	// The review aggregates of both movies are recomputed from scratch rather than adjusted
+	`UPDATE movies
+	SET rating_sum = COALESCE((SELECT SUM(rating) FROM reviews WHERE reviews.movie_id = movies.id), 0),
+		rating_count = (SELECT COUNT(*) FROM reviews WHERE reviews.movie_id = movies.id)
+	WHERE id IN (@source, @target)`,
+
+	// Remove the source from the lists which already have the target and close the gap it leaves
+	`WITH removed AS (
+		DELETE FROM list_items
+		WHERE movie_id = @source AND list_id IN (SELECT list_id FROM list_items WHERE movie_id = @target)
+		RETURNING list_id, position
+	)
+	UPDATE list_items SET position = list_items.position - 1
+	FROM removed
+	WHERE list_items.list_id = removed.list_id AND list_items.position > removed.position`,
+	`UPDATE list_items SET movie_id = @target WHERE movie_id = @source`,
+
+	// Same for the collections
+	`WITH removed AS (
+		DELETE FROM collection_movies
+		WHERE movie_id = @source AND collection_id IN (SELECT collection_id FROM collection_movies WHERE movie_id = @target)
+		RETURNING collection_id, position
+	)
+	UPDATE collection_movies SET position = collection_movies.position - 1
+	FROM removed
+	WHERE collection_movies.collection_id = removed.collection_id AND collection_movies.position > removed.position`,
+	`UPDATE collection_movies SET movie_id = @target WHERE movie_id = @source`,
+
+	`UPDATE movie_credits SET movie_id = @target
+	WHERE movie_id = @source AND NOT EXISTS (
+		SELECT 1 FROM movie_credits t
+		WHERE t.movie_id = @target AND t.person_id = movie_credits.person_id
+			AND t.role = movie_credits.role AND t.character_name = movie_credits.character_name
+	)`,
+
+	`UPDATE watch_history SET movie_id = @target WHERE movie_id = @source`,
+	`UPDATE movie_images SET movie_id = @target WHERE movie_id = @source`,
+
+	`UPDATE movie_titles SET movie_id = @target
+	WHERE movie_id = @source AND locale NOT IN (SELECT locale FROM movie_titles WHERE movie_id = @target)`,
+	`UPDATE movie_release_dates SET movie_id = @target
+	WHERE movie_id = @source AND country NOT IN (SELECT country FROM movie_release_dates WHERE movie_id = @target)`,
+	`UPDATE movie_certifications SET movie_id = @target
+	WHERE movie_id = @source AND country NOT IN (SELECT country FROM movie_certifications WHERE movie_id = @target)`,
+	`UPDATE external_ids SET movie_id = @target
+	WHERE movie_id = @source AND source NOT IN (SELECT source FROM external_ids WHERE movie_id = @target)`,
+
+	// Similarities are recomputed by the scheduled job
+	`DELETE FROM movie_similarities WHERE movie_id = @source OR similar_movie_id = @source`,
+
+	`UPDATE movies SET merged_into = @target WHERE merged_into = @source`,
+	`UPDATE movies SET deleted_at = NOW(), merged_into = @target, version = version + 1 WHERE id = @source`,
+}
+
+// Merge merges the movie sourceID into the movie targetID in a single transaction: the reviews, list items,
+// credits and the other data of the source are moved to the target, then the source is soft deleted.
+// It returns the target movie, or ErrRecordNotFound if either movie does not exist.
+func (m MovieModel) Merge(ctx context.Context, sourceID, targetID int64) (*data.Movie, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	// Lock both movies in a consistent order so that concurrent merges can't deadlock
+	rows, err := tx.Query(ctxWithTimeout, `
+		SELECT id FROM movies
+		WHERE id IN ($1, $2) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE;`, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+	if len(locked) != 2 {
+		return nil, ErrRecordNotFound
+	}
+
+	args := pgx.NamedArgs{"source": sourceID, "target": targetID}
+	for _, statement := range mergeStatements {
+		if _, err := tx.Exec(ctxWithTimeout, statement, args); err != nil {
+			return nil, fmt.Errorf("error merging movie %d into %d %w", sourceID, targetID, err)
+		}
+	}
+
+	var movie data.Movie
+	err = tx.QueryRow(ctxWithTimeout, `
+		SELECT id, created_at, title, year, runtime, genres, version, average_rating, rating_count
+		FROM movies
+		WHERE id = $1;`, targetID).Scan(
+		&movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, &movie.Genres,
+		&movie.Version, &movie.AverageRating, &movie.RatingCount,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(ctxWithTimeout); err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
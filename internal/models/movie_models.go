@@ -7,52 +7,134 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 )
 
 type MovieModel struct {
-	DB *pgxpool.Pool
+	DB *DB
 }
 
-func (m MovieModel) Create(movie *data.Movie) error {
+func (m MovieModel) Create(ctx context.Context, movie *data.Movie) error {
 	query := `
 		INSERT INTO movies (title, year, runtime, genres)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, version;
+		RETURNING id, created_at, version, average_rating, rating_count;
 	`
 	args := []any{movie.Title, movie.Year, movie.Runtime, movie.Genres}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
-	return m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	return m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &movie.AverageRating, &movie.RatingCount)
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters data.Filters) ([]data.Movie, data.Metadata, error) {
+// movieFilterCondition is the WHERE condition shared by every query that lists movies through
+// [data.MovieFilters]. Every filter is passed in as a named argument (see [movieFilterArgs]), a filter
+// with its zero value matches every movie. Merged movies are always left out.
+const movieFilterCondition = `
+	deleted_at IS NULL
+	AND (@title = '' OR to_tsvector('simple', title) @@ plainto_tsquery('simple', @title) OR EXISTS (
+		SELECT 1 FROM movie_titles
+		WHERE movie_titles.movie_id = movies.id AND to_tsvector('simple', movie_titles.title) @@ plainto_tsquery('simple', @title)
+	))
+	AND (genres @> @genres OR @genres = '{}')
+	AND (genres && @genres_any OR @genres_any = '{}')
+	AND (NOT genres && @genres_none OR @genres_none = '{}')
+	AND (year >= @year_min OR @year_min = 0)
+	AND (year <= @year_max OR @year_max = 0)
+	AND (runtime >= @runtime_min OR @runtime_min = 0)
+	AND (runtime <= @runtime_max OR @runtime_max = 0)
+	AND (created_at > @created_after OR @created_after::timestamptz IS NULL)
+	AND (@person_id = 0 OR EXISTS (
+		SELECT 1 FROM movie_credits WHERE movie_credits.movie_id = movies.id AND movie_credits.person_id = @person_id
+	))
+	AND (@collection_id = 0 OR EXISTS (
+		SELECT 1 FROM collection_movies WHERE collection_movies.movie_id = movies.id AND collection_movies.collection_id = @collection_id
+	))
+	AND ` + ratingCondition
+
+// ratingCondition leaves out the movies rated above the limit passed in by [ratingArgs], it mirrors
+// [data.Certification.Allows]. A NULL max_age allows every movie.
+const ratingCondition = `(@max_age::int IS NULL OR COALESCE(
+		(SELECT min_age FROM movie_certifications WHERE movie_id = movies.id AND country = @rating_country),
+		(SELECT max(min_age) FROM movie_certifications WHERE movie_id = movies.id),
+		0
+	) <= @max_age)`
+
+// ratingArgs adds the named arguments referenced by [ratingCondition] to args, a nil limit allows every movie
+func ratingArgs(args pgx.NamedArgs, maxRating *data.Certification) pgx.NamedArgs {
+	var maxAge *int
+	var ratingCountry string
+	if maxRating != nil {
+		age := maxRating.MinAge()
+		maxAge = &age
+		ratingCountry = maxRating.Country
+	}
+	args["max_age"] = maxAge
+	args["rating_country"] = ratingCountry
+	return args
+}
+
+// movieFilterArgs returns the named arguments referenced by [movieFilterCondition]
+func movieFilterArgs(f data.MovieFilters) pgx.NamedArgs {
+	// Use empty slices rather than nil so that the genres arguments are sent as '{}' instead of NULL
+	orEmpty := func(s []string) []string {
+		if s == nil {
+			return []string{}
+		}
+		return s
+	}
+	var createdAfter *time.Time
+	if !f.CreatedAfter.IsZero() {
+		createdAfter = &f.CreatedAfter
+	}
+	return ratingArgs(pgx.NamedArgs{
+		"title":         f.Title,
+		"genres":        orEmpty(f.Genres),
+		"genres_any":    orEmpty(f.GenresAny),
+		"genres_none":   orEmpty(f.GenresNone),
+		"year_min":      f.YearMin,
+		"year_max":      f.YearMax,
+		"runtime_min":   f.RuntimeMin,
+		"runtime_max":   f.RuntimeMax,
+		"created_after": createdAfter,
+		"person_id":     f.PersonID,
+		"collection_id": f.CollectionID,
+	}, f.MaxRating)
+}
+
+func (m MovieModel) GetAll(ctx context.Context, movieFilters data.MovieFilters, filters data.Filters) ([]data.Movie, data.Metadata, error) {
+	orderBy, err := filters.OrderBy()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
 	// Use COUNT(*) OVER() to get total count along with the result rows
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER() AS count, id, created_at, title, year, runtime, genres, version	
+		SELECT COUNT(*) OVER() AS count, id, created_at, title, year, runtime, genres, version, average_rating, rating_count
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) or $1 = '')
-			AND (genres @> $2 or $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4;`,
-		filters.SortColumn(), filters.SortDirection(),
+		WHERE %s
+		ORDER BY %s, id ASC
+		LIMIT @limit OFFSET @offset;`,
+		movieFilterCondition, orderBy,
 	)
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
+	args := movieFilterArgs(movieFilters)
+	args["limit"] = filters.Limit()
+	args["offset"] = filters.Offset()
+
 	totalRecords := 0
 	movie := data.Movie{}
 	movies := make([]data.Movie, 0)
-	rows, err := m.DB.Query(ctx, query, title, genres, filters.Limit(), filters.Offset())
+	rows, err := m.DB.Query(ctx, query, args)
 	if err != nil {
 		return nil, data.Metadata{}, err
 	}
 	defer rows.Close()
 	for rows.Next() {
-		err := rows.Scan(&totalRecords, &movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, &movie.Genres, &movie.Version)
+		err := rows.Scan(&totalRecords, &movie.ID, &movie.CreatedAt, &movie.Title, &movie.Year, &movie.Runtime, &movie.Genres, &movie.Version, &movie.AverageRating, &movie.RatingCount)
 		if err != nil {
 			return nil, data.Metadata{}, err
 		}
@@ -66,7 +148,7 @@ func (m MovieModel) GetAll(title string, genres []string, filters data.Filters)
 	return movies, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
 }
 
-func (m MovieModel) Get(id int64) (*data.Movie, error) {
+func (m MovieModel) Get(ctx context.Context, id int64) (*data.Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -78,14 +160,16 @@ func (m MovieModel) Get(id int64) (*data.Movie, error) {
 	    year,
 	    runtime,
 	    genres,
-	    version
+	    version,
+	    average_rating,
+	    rating_count
 	FROM movies
-	WHERE id = $1;
+	WHERE id = $1 AND deleted_at IS NULL;
 	`
 
 	movie := data.Movie{}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 	err := m.DB.QueryRow(ctxWithTimeout, query, id).Scan(
 		&movie.ID,
@@ -95,6 +179,8 @@ func (m MovieModel) Get(id int64) (*data.Movie, error) {
 		&movie.Runtime,
 		&movie.Genres,
 		&movie.Version,
+		&movie.AverageRating,
+		&movie.RatingCount,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return &movie, ErrRecordNotFound
@@ -102,17 +188,17 @@ func (m MovieModel) Get(id int64) (*data.Movie, error) {
 	return &movie, err
 }
 
-func (m *MovieModel) Update(movie *data.Movie) error {
+func (m MovieModel) Update(ctx context.Context, movie *data.Movie) error {
 	// Use version to prevent data race condition to update. This can be consider as optimistic locking
 	query := `
 		UPDATE movies
 		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 		RETURNING version;
 	`
 	args := []any{movie.Title, movie.Year, movie.Runtime, movie.Genres, movie.ID, movie.Version}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 	if err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&movie.Version); err != nil {
 
@@ -124,18 +210,77 @@ func (m *MovieModel) Update(movie *data.Movie) error {
 	return nil
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Delete removes the movie and the rows referencing it. Its list items and collection memberships would be
+// removed by the ON DELETE CASCADE as well, they are removed first so that the gaps they leave in the
+// positions are closed.
+func (m MovieModel) Delete(ctx context.Context, id int64) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	for _, ordered := range []orderedMovies{listItems, collectionMovies} {
+		if err := ordered.removeMovie(ctxWithTimeout, tx, id); err != nil {
+			return err
+		}
+	}
+
 	query := `
 		DELETE FROM movies
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
-
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	result, err := m.DB.Exec(ctxWithTimeout, query, id)
+	result, err := tx.Exec(ctxWithTimeout, query, id)
+	if err != nil {
+		return err
+	}
 	if result.RowsAffected() == 0 {
 		return ErrRecordNotFound
 	}
-	return err
+	return tx.Commit(ctxWithTimeout)
+}
+
+// GetFacets counts the movies matching movieFilters for every value of the requested facets.
+// It uses the same conditions as [MovieModel.GetAll] so that the counts line up with the listing.
+func (m MovieModel) GetFacets(ctx context.Context, movieFilters data.MovieFilters, facets []string) (data.Facets, error) {
+	result := make(data.Facets, len(facets))
+	for _, facet := range facets {
+		var query string
+		switch facet {
+		case data.FacetGenres:
+			query = `
+				SELECT genre, COUNT(*) AS count
+				FROM movies, unnest(genres) AS genre
+				WHERE ` + movieFilterCondition + `
+				GROUP BY genre
+				ORDER BY count DESC, genre ASC;`
+		case data.FacetDecade:
+			query = `
+				SELECT ((year / 10) * 10)::text || 's' AS decade, COUNT(*) AS count
+				FROM movies
+				WHERE ` + movieFilterCondition + `
+				GROUP BY year / 10
+				ORDER BY year / 10 ASC;`
+		default:
+			return nil, fmt.Errorf("unsupported facet: %s", facet)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+		rows, err := m.DB.Query(ctx, query, movieFilterArgs(movieFilters))
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("error when Query in GetFacets %w", err)
+		}
+		buckets, err := pgx.CollectRows(rows, pgx.RowToStructByPos[data.FacetBucket])
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+		}
+		result[facet] = buckets
+	}
+	return result, nil
 }
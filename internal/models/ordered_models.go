@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// orderedMovies manages the positions of the movies of an ordered parent, such as the items of a list or
+// the members of a collection. The positions of a parent always run from 1 to its number of movies, every
+// change locks the parent row so that concurrent changes to its positions are serialized.
+type orderedMovies struct {
+	table        string // Table of the movies, with a movie_id and a position column
+	parentTable  string
+	parentColumn string // Column of table referencing the id of parentTable
+}
+
+var (
+	listItems        = orderedMovies{table: "list_items", parentTable: "lists", parentColumn: "list_id"}
+	collectionMovies = orderedMovies{table: "collection_movies", parentTable: "collections", parentColumn: "collection_id"}
+)
+
+// lock locks the parent row and returns its current number of movies, [ErrRecordNotFound] when the parent
+// does not exist
+func (o orderedMovies) lock(ctx context.Context, tx pgx.Tx, parentID int64) (int32, error) {
+	result, err := tx.Exec(ctx, `SELECT id FROM `+o.parentTable+` WHERE id = $1 FOR UPDATE;`, parentID)
+	if err != nil {
+		return 0, err
+	}
+	if result.RowsAffected() == 0 {
+		return 0, ErrRecordNotFound
+	}
+	var size int32
+	err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM `+o.table+` WHERE `+o.parentColumn+` = $1;`, parentID).Scan(&size)
+	return size, err
+}
+
+// makeRoom frees the position for a new movie by shifting the following movies down. A position of 0 or
+// past the end appends the movie, the position to insert it at is returned.
+func (o orderedMovies) makeRoom(ctx context.Context, tx pgx.Tx, parentID int64, position int32) (int32, error) {
+	size, err := o.lock(ctx, tx, parentID)
+	if err != nil {
+		return 0, err
+	}
+	if position == 0 || position > size+1 {
+		position = size + 1
+	}
+
+	query := `UPDATE ` + o.table + ` SET position = position + 1 WHERE ` + o.parentColumn + ` = $1 AND position >= $2;`
+	if _, err := tx.Exec(ctx, query, parentID, position); err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+// move shifts the movies between the current position of the movie and its new one, a position of 0 or
+// past the end moves it last. The caller saves the returned position on the row of the movie.
+func (o orderedMovies) move(ctx context.Context, tx pgx.Tx, parentID, movieID int64, position int32) (int32, error) {
+	size, err := o.lock(ctx, tx, parentID)
+	if err != nil {
+		return 0, err
+	}
+	if position == 0 || position > size {
+		position = size
+	}
+
+	var previousPosition int32
+	query := `SELECT position FROM ` + o.table + ` WHERE ` + o.parentColumn + ` = $1 AND movie_id = $2;`
+	if err := tx.QueryRow(ctx, query, parentID, movieID).Scan(&previousPosition); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+
+	// Moving a movie up pushes the movies in between down and vice versa
+	query = `
+		UPDATE ` + o.table + `
+		SET position = position + CASE WHEN $2 < $3 THEN 1 ELSE -1 END
+		WHERE ` + o.parentColumn + ` = $1 AND position BETWEEN LEAST($2, $3) AND GREATEST($2, $3) AND position <> $3;
+	`
+	if _, err := tx.Exec(ctx, query, parentID, position, previousPosition); err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+// remove deletes the movie from the parent and closes the gap in the positions
+func (o orderedMovies) remove(ctx context.Context, tx pgx.Tx, parentID, movieID int64) error {
+	if _, err := o.lock(ctx, tx, parentID); err != nil {
+		return err
+	}
+
+	var position int32
+	query := `DELETE FROM ` + o.table + ` WHERE ` + o.parentColumn + ` = $1 AND movie_id = $2 RETURNING position;`
+	if err := tx.QueryRow(ctx, query, parentID, movieID).Scan(&position); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	query = `UPDATE ` + o.table + ` SET position = position - 1 WHERE ` + o.parentColumn + ` = $1 AND position > $2;`
+	_, err := tx.Exec(ctx, query, parentID, position)
+	return err
+}
+
+// removeMovie deletes the movie from every parent and closes the gaps, before the movie itself is deleted.
+// The ON DELETE CASCADE would delete the rows as well but leave the gaps.
+func (o orderedMovies) removeMovie(ctx context.Context, tx pgx.Tx, movieID int64) error {
+	query := `
+		SELECT id FROM ` + o.parentTable + `
+		WHERE id IN (SELECT ` + o.parentColumn + ` FROM ` + o.table + ` WHERE movie_id = $1)
+		ORDER BY id
+		FOR UPDATE;
+	`
+	if _, err := tx.Exec(ctx, query, movieID); err != nil {
+		return err
+	}
+
+	// A movie is at most once in a parent, so every parent has a single gap to close
+	query = `
+		WITH removed AS (
+			DELETE FROM ` + o.table + ` WHERE movie_id = $1
+			RETURNING ` + o.parentColumn + `, position
+		)
+		UPDATE ` + o.table + ` SET position = ` + o.table + `.position - 1
+		FROM removed
+		WHERE ` + o.table + `.` + o.parentColumn + ` = removed.` + o.parentColumn + ` AND ` + o.table + `.position > removed.position;
+	`
+	_, err := tx.Exec(ctx, query, movieID)
+	return err
+}
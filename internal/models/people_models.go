@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type PersonModel struct {
+	DB *DB
+}
+
+func (m PersonModel) Create(ctx context.Context, person *data.Person) error {
+	query := `
+		INSERT INTO people (name, biography)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRow(ctxWithTimeout, query, person.Name, person.Biography).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m PersonModel) GetAll(ctx context.Context, name string, filters data.Filters) ([]data.Person, data.Metadata, error) {
+	orderBy, err := filters.OrderBy()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER() AS count, id, created_at, name, biography, version
+		FROM people
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s, id ASC
+		LIMIT $2 OFFSET $3;`,
+		orderBy,
+	)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, name, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	people := make([]data.Person, 0)
+	for rows.Next() {
+		var person data.Person
+		if err := rows.Scan(&totalRecords, &person.ID, &person.CreatedAt, &person.Name, &person.Biography, &person.Version); err != nil {
+			return nil, data.Metadata{}, err
+		}
+		people = append(people, person)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	return people, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m PersonModel) Get(ctx context.Context, id int64) (*data.Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+	SELECT id, created_at, name, biography, version
+	FROM people
+	WHERE id = $1;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	var person data.Person
+	err := m.DB.QueryRow(ctxWithTimeout, query, id).Scan(&person.ID, &person.CreatedAt, &person.Name, &person.Biography, &person.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &person, nil
+}
+
+func (m PersonModel) Update(ctx context.Context, person *data.Person) error {
+	// Use version to prevent data race condition to update. This can be consider as optimistic locking
+	query := `
+		UPDATE people
+		SET name = $1, biography = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version;
+	`
+	args := []any{person.Name, person.Biography, person.ID, person.Version}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+	if err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&person.Version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (m PersonModel) Delete(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM people
+		WHERE id = $1
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.Exec(ctxWithTimeout, query, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -4,19 +4,17 @@ import (
 	"context"
 	"fmt"
 	"slices"
-	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PermissionModel struct {
-	DB *pgxpool.Pool
+	DB *DB
 }
 
 type Permissions []string
 
-func (m PermissionModel) GetAllForUser(userId int64) (Permissions, error) {
+func (m PermissionModel) GetAllForUser(ctx context.Context, userId int64) (Permissions, error) {
 	query := `
 	SELECT permissions.code
 	FROM permissions
@@ -25,7 +23,7 @@ func (m PermissionModel) GetAllForUser(userId int64) (Permissions, error) {
 	WHERE users.id = $1;
 	`
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.Query(ctxWithTimeout, query, userId)
@@ -41,12 +39,28 @@ func (m PermissionModel) GetAllForUser(userId int64) (Permissions, error) {
 	return permissions, nil
 }
 
-func (m *PermissionModel) AddForUser(userId int64, permissions ...string) error {
+func (m PermissionModel) AddForUser(ctx context.Context, userId int64, permissions ...string) error {
 	query := `
 	INSERT INTO users_permissions
 	SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2);
 	`
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.Exec(ctxWithTimeout, query, userId, permissions)
+	return err
+}
+
+// RemoveForUser revokes the permissions of the user, the codes the user doesn't have are ignored
+func (m PermissionModel) RemoveForUser(ctx context.Context, userId int64, permissions ...string) error {
+	query := `
+	DELETE FROM users_permissions
+	USING permissions
+	WHERE users_permissions.permission_id = permissions.id
+	AND users_permissions.user_id = $1
+	AND permissions.code = ANY($2);
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
 	_, err := m.DB.Exec(ctxWithTimeout, query, userId, permissions)
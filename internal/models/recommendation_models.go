@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+const (
+	// similarityGenreWeight is the weight of the genre similarity in the combined score, the rest comes
+	// from the co-ratings
+	similarityGenreWeight = 0.5
+	// similarityNeighbors is the number of neighbors kept for each movie
+	similarityNeighbors = 50
+	// similarityMinCoRatings is the number of users who must have rated both movies for the co-ratings to count
+	similarityMinCoRatings = 2
+	// similarityLockID is the key of the advisory lock taken by the refresh, so that only one replica runs it
+	similarityLockID = 0x6d6f7669655f7369 // "movie_si"
+)
+
+type RecommendationModel struct {
+	DB *DB
+}
+
+// RefreshSimilarities recomputes the neighbors of every movie. The table is replaced in a single
+// transaction so that readers always see a complete set of neighbors. It returns [ErrLocked] without doing
+// anything while another instance of the API is refreshing.
+func (m RecommendationModel) RefreshSimilarities(ctx context.Context) error {
+	// This is a batch job over the whole catalog, give it more time than a regular query
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	// The lock is released with the transaction
+	var locked bool
+	if err := tx.QueryRow(ctxWithTimeout, `SELECT pg_try_advisory_xact_lock($1);`, int64(similarityLockID)).Scan(&locked); err != nil {
+		return fmt.Errorf("error locking movie similarities %w", err)
+	}
+	if !locked {
+		return ErrLocked
+	}
+
+	if _, err := tx.Exec(ctxWithTimeout, `DELETE FROM movie_similarities;`); err != nil {
+		return fmt.Errorf("error clearing movie similarities %w", err)
+	}
+
+	query := `
+	WITH genre_scores AS (
+		-- Jaccard similarity: shared genres over the genres of both movies
+		SELECT a.id AS movie_id, b.id AS similar_movie_id,
+			cardinality(ARRAY(SELECT unnest(a.genres) INTERSECT SELECT unnest(b.genres)))::double precision
+			/ cardinality(ARRAY(SELECT unnest(a.genres) UNION SELECT unnest(b.genres))) AS score
+		FROM movies a
+		INNER JOIN movies b ON a.id <> b.id AND a.genres && b.genres
+		WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL
+	),
+	centered AS (
+		-- Remove the bias of each user so that a 6 from a harsh user weighs like an 8 from a generous one
+		SELECT user_id, movie_id, rating - AVG(rating) OVER (PARTITION BY user_id) AS rating
+		FROM reviews
+		WHERE movie_id IN (SELECT id FROM movies WHERE deleted_at IS NULL)
+	),
+	rating_scores AS (
+		-- Adjusted cosine similarity over the users who rated both movies
+		SELECT r1.movie_id, r2.movie_id AS similar_movie_id,
+			SUM(r1.rating * r2.rating)
+			/ NULLIF(sqrt(SUM(r1.rating * r1.rating)) * sqrt(SUM(r2.rating * r2.rating)), 0) AS score
+		FROM centered r1
+		INNER JOIN centered r2 ON r1.user_id = r2.user_id AND r1.movie_id <> r2.movie_id
+		GROUP BY r1.movie_id, r2.movie_id
+		HAVING COUNT(*) >= @min_co_ratings
+	),
+	combined AS (
+		SELECT COALESCE(g.movie_id, r.movie_id) AS movie_id,
+			COALESCE(g.similar_movie_id, r.similar_movie_id) AS similar_movie_id,
+			COALESCE(g.score, 0) AS genre_score,
+			GREATEST(COALESCE(r.score, 0), 0) AS rating_score
+		FROM genre_scores g
+		FULL OUTER JOIN rating_scores r ON g.movie_id = r.movie_id AND g.similar_movie_id = r.similar_movie_id
+	),
+	ranked AS (
+		SELECT movie_id, similar_movie_id, genre_score, rating_score,
+			@genre_weight * genre_score + (1 - @genre_weight) * rating_score AS score,
+			row_number() OVER (
+				PARTITION BY movie_id
+				ORDER BY @genre_weight * genre_score + (1 - @genre_weight) * rating_score DESC, similar_movie_id
+			) AS rank
+		FROM combined
+	)
+	INSERT INTO movie_similarities (movie_id, similar_movie_id, genre_score, rating_score, score)
+	SELECT movie_id, similar_movie_id, genre_score, rating_score, score
+	FROM ranked
+	WHERE rank <= @neighbors AND score > 0;
+	`
+	args := pgx.NamedArgs{
+		"min_co_ratings": similarityMinCoRatings,
+		"genre_weight":   similarityGenreWeight,
+		"neighbors":      similarityNeighbors,
+	}
+	if _, err := tx.Exec(ctxWithTimeout, query, args); err != nil {
+		return fmt.Errorf("error computing movie similarities %w", err)
+	}
+
+	return tx.Commit(ctxWithTimeout)
+}
+
+// GetSimilar returns the precomputed neighbors of a movie, most similar first. The movies rated above
+// maxRating are left out, a nil maxRating allows every movie.
+func (m RecommendationModel) GetSimilar(ctx context.Context, movieID int64, limit int, maxRating *data.Certification) ([]data.ScoredMovie, error) {
+	query := `
+	SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+		movies.version, movies.average_rating, movies.rating_count, movie_similarities.score
+	FROM movie_similarities
+	INNER JOIN movies ON movies.id = movie_similarities.similar_movie_id
+	WHERE movie_similarities.movie_id = @movie_id AND movies.deleted_at IS NULL AND ` + ratingCondition + `
+	ORDER BY movie_similarities.score DESC, movies.id
+	LIMIT @limit;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	args := ratingArgs(pgx.NamedArgs{"movie_id": movieID, "limit": limit}, maxRating)
+	rows, err := m.DB.Query(ctxWithTimeout, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetSimilar %w", err)
+	}
+	return collectScoredMovies(rows)
+}
+
+// GetForUser recommends movies the user has not rated nor watched yet. Every movie the user rated
+// or watched votes for its neighbors: well rated movies push their neighbors up, poorly rated movies
+// push them down and movies only watched count as a mild positive signal. The movies rated above maxRating
+// are left out.
+func (m RecommendationModel) GetForUser(ctx context.Context, userID int64, limit int, maxRating *data.Certification) ([]data.ScoredMovie, error) {
+	query := `
+	WITH seeds AS (
+		SELECT movie_id, (rating - 5.5) / 4.5 AS weight
+		FROM reviews
+		WHERE user_id = @user_id
+		UNION ALL
+		SELECT DISTINCT movie_id, 0.5 AS weight
+		FROM watch_history
+		WHERE user_id = @user_id AND movie_id NOT IN (SELECT movie_id FROM reviews WHERE user_id = @user_id)
+	),
+	candidates AS (
+		SELECT movie_similarities.similar_movie_id AS movie_id, SUM(seeds.weight * movie_similarities.score) AS score
+		FROM seeds
+		INNER JOIN movie_similarities ON movie_similarities.movie_id = seeds.movie_id
+		WHERE movie_similarities.similar_movie_id NOT IN (SELECT movie_id FROM seeds)
+		GROUP BY movie_similarities.similar_movie_id
+		HAVING SUM(seeds.weight * movie_similarities.score) > 0
+	)
+	SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres,
+		movies.version, movies.average_rating, movies.rating_count, candidates.score
+	FROM candidates
+	INNER JOIN movies ON movies.id = candidates.movie_id AND movies.deleted_at IS NULL
+	WHERE ` + ratingCondition + `
+	ORDER BY candidates.score DESC, movies.id
+	LIMIT @limit;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	args := ratingArgs(pgx.NamedArgs{"user_id": userID, "limit": limit}, maxRating)
+	rows, err := m.DB.Query(ctxWithTimeout, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("error when Query in GetForUser %w", err)
+	}
+	return collectScoredMovies(rows)
+}
+
+func collectScoredMovies(rows pgx.Rows) ([]data.ScoredMovie, error) {
+	movies, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (data.ScoredMovie, error) {
+		var s data.ScoredMovie
+		err := row.Scan(
+			&s.Movie.ID,
+			&s.Movie.CreatedAt,
+			&s.Movie.Title,
+			&s.Movie.Year,
+			&s.Movie.Runtime,
+			&s.Movie.Genres,
+			&s.Movie.Version,
+			&s.Movie.AverageRating,
+			&s.Movie.RatingCount,
+			&s.Score,
+		)
+		return s, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgx.CollectRows error %w", err)
+	}
+	return movies, nil
+}
@@ -0,0 +1,527 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+	"github.com/nguyenanhhao221/greenlight-api/internal/migrate"
+	"github.com/nguyenanhhao221/greenlight-api/migrations"
+)
+
+// testDSNEnv names the variable holding the DSN of a disposable database, the PostgreSQL backend is only
+// tested when it is set. The database is migrated to the latest version before the tests.
+const testDSNEnv = "GREENLIGHT_TEST_DB_DSN"
+
+// backends returns the models of every backend available to the contract tests, by name
+func backends(t *testing.T) map[string]Models {
+	t.Helper()
+	backends := map[string]Models{"memory": NewMemory()}
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Logf("%s is not set, only the memory backend is tested", testDSNEnv)
+		return backends
+	}
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", testDSNEnv, err)
+	}
+	defer conn.Close(ctx)
+	migrator, err := migrate.New(conn, migrations.FS, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrating the test database: %v", err)
+	}
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	backends["postgres"] = New(NewDB(pool, DefaultQueryTimeout))
+	return backends
+}
+
+// uniqueEmail returns an email no other test used, the PostgreSQL database is not emptied between runs
+func uniqueEmail(name string) string {
+	return fmt.Sprintf("%s-%d@example.com", strings.ReplaceAll(name, " ", "-"), time.Now().UnixNano())
+}
+
+// createUser stores a user with a dummy password hash, hashing a real password is slow and not needed here
+func createUser(t *testing.T, m Models, name string) *data.User {
+	t.Helper()
+	user := &data.User{Name: name, Email: uniqueEmail(name), Password: data.Password{Hash: []byte("hash")}, Activated: true}
+	if err := m.User.Create(context.Background(), user); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return user
+}
+
+func createMovie(t *testing.T, m Models) *data.Movie {
+	t.Helper()
+	movie := &data.Movie{Title: "Contract Test", Year: 2001, Runtime: 100, Genres: []string{"drama"}}
+	if err := m.Movie.Create(context.Background(), movie); err != nil {
+		t.Fatalf("creating movie: %v", err)
+	}
+	return movie
+}
+
+func TestMovieRepository(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, m Models)
+	}{
+		{"create then get", func(t *testing.T, m Models) {
+			movie := createMovie(t, m)
+			if movie.ID < 1 || movie.Version != 1 {
+				t.Fatalf("got id %d and version %d, want a positive id and version 1", movie.ID, movie.Version)
+			}
+			got, err := m.Movie.Get(ctx, movie.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != movie.Title || got.Year != movie.Year || got.Runtime != movie.Runtime || !slicesEqual(got.Genres, movie.Genres) {
+				t.Errorf("got %+v, want %+v", got, movie)
+			}
+		}},
+		{"get a missing movie", func(t *testing.T, m Models) {
+			for _, id := range []int64{0, 1 << 40} {
+				if _, err := m.Movie.Get(ctx, id); !errors.Is(err, ErrRecordNotFound) {
+					t.Errorf("Get(%d) error = %v, want ErrRecordNotFound", id, err)
+				}
+			}
+		}},
+		{"update increments the version", func(t *testing.T, m Models) {
+			movie := createMovie(t, m)
+			movie.Title = "Contract Test Updated"
+			if err := m.Movie.Update(ctx, movie); err != nil {
+				t.Fatal(err)
+			}
+			if movie.Version != 2 {
+				t.Errorf("got version %d, want 2", movie.Version)
+			}
+			got, err := m.Movie.Get(ctx, movie.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != movie.Title || got.Version != 2 {
+				t.Errorf("got %q version %d, want %q version 2", got.Title, got.Version, movie.Title)
+			}
+		}},
+		{"update with a stale version conflicts", func(t *testing.T, m Models) {
+			movie := createMovie(t, m)
+			stale := *movie
+			if err := m.Movie.Update(ctx, movie); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Movie.Update(ctx, &stale); !errors.Is(err, ErrEditConflict) {
+				t.Errorf("error = %v, want ErrEditConflict", err)
+			}
+		}},
+		{"delete", func(t *testing.T, m Models) {
+			movie := createMovie(t, m)
+			if err := m.Movie.Delete(ctx, movie.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Movie.Get(ctx, movie.ID); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("Get after Delete error = %v, want ErrRecordNotFound", err)
+			}
+			if err := m.Movie.Delete(ctx, movie.ID); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("second Delete error = %v, want ErrRecordNotFound", err)
+			}
+		}},
+	}
+
+	for backend, m := range backends(t) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) { tt.run(t, m) })
+		}
+	}
+}
+
+func TestUserRepository(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, m Models)
+	}{
+		{"get by email ignores the case", func(t *testing.T, m Models) {
+			user := createUser(t, m, "get by email")
+			got, err := m.User.GetByEmail(ctx, strings.ToUpper(user.Email))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != user.ID || got.Version != 1 {
+				t.Errorf("got id %d version %d, want id %d version 1", got.ID, got.Version, user.ID)
+			}
+		}},
+		{"duplicate email", func(t *testing.T, m Models) {
+			user := createUser(t, m, "duplicate email")
+			duplicate := &data.User{Name: "other", Email: strings.ToUpper(user.Email), Password: data.Password{Hash: []byte("hash")}}
+			if err := m.User.Create(ctx, duplicate); !errors.Is(err, ErrDuplicateEmail) {
+				t.Errorf("error = %v, want ErrDuplicateEmail", err)
+			}
+		}},
+		{"update with a stale version conflicts", func(t *testing.T, m Models) {
+			user := createUser(t, m, "stale user")
+			stale := *user
+			user.Name = "renamed"
+			if err := m.User.Update(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			if user.Version != 2 {
+				t.Errorf("got version %d, want 2", user.Version)
+			}
+			if err := m.User.Update(ctx, &stale); !errors.Is(err, ErrEditConflict) {
+				t.Errorf("error = %v, want ErrEditConflict", err)
+			}
+		}},
+		{"update to a taken email", func(t *testing.T, m Models) {
+			user := createUser(t, m, "taken email")
+			other := createUser(t, m, "taken email other")
+			user.Email = other.Email
+			if err := m.User.Update(ctx, user); !errors.Is(err, ErrDuplicateEmail) {
+				t.Errorf("error = %v, want ErrDuplicateEmail", err)
+			}
+		}},
+	}
+
+	for backend, m := range backends(t) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) { tt.run(t, m) })
+		}
+	}
+}
+
+func TestTokenRepository(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		scope     string // Scope of the lookup, the token is created for authentication
+		wantFound bool
+	}{
+		{"valid token", time.Hour, data.ScopeAuthentication, true},
+		{"expired token", -time.Hour, data.ScopeAuthentication, false},
+		{"other scope", time.Hour, data.ScopeActivation, false},
+	}
+
+	for backend, m := range backends(t) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				user := createUser(t, m, tt.name)
+				token, err := m.Token.New(ctx, user.ID, tt.ttl, data.ScopeAuthentication)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := m.User.GetUserWithToken(ctx, token.Plain, tt.scope)
+				switch {
+				case tt.wantFound && err != nil:
+					t.Fatalf("error = %v, want the user", err)
+				case tt.wantFound && got.ID != user.ID:
+					t.Errorf("got user %d, want %d", got.ID, user.ID)
+				case !tt.wantFound && !errors.Is(err, ErrRecordNotFound):
+					t.Errorf("error = %v, want ErrRecordNotFound", err)
+				}
+			})
+		}
+	}
+}
+
+// TestCleanupRepository covers the token and permission cleanups of the admin CLI
+func TestCleanupRepository(t *testing.T) {
+	ctx := context.Background()
+	for backend, m := range backends(t) {
+		t.Run(backend+"/tokens", func(t *testing.T) {
+			user := createUser(t, m, "delete tokens")
+			expired, err := m.Token.New(ctx, user.ID, -time.Hour, data.ScopeAuthentication)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Token.New(ctx, user.ID, time.Hour, data.ScopeAuthentication); err != nil {
+				t.Fatal(err)
+			}
+
+			// Other tests may have left expired tokens in the database, at least this one is deleted
+			if n, err := m.Token.DeleteExpired(ctx); err != nil || n < 1 {
+				t.Errorf("DeleteExpired = %d, %v, want at least 1", n, err)
+			}
+			if _, err := m.User.GetUserWithToken(ctx, expired.Plain, data.ScopeAuthentication); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("expired token lookup error = %v, want ErrRecordNotFound", err)
+			}
+			if n, err := m.Token.DeleteAllForUser(ctx, user.ID); err != nil || n != 1 {
+				t.Errorf("DeleteAllForUser = %d, %v, want 1", n, err)
+			}
+		})
+
+		t.Run(backend+"/permissions", func(t *testing.T) {
+			user := createUser(t, m, "remove permissions")
+			if err := m.Permission.AddForUser(ctx, user.ID, "movies:read", "movies:write"); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Permission.RemoveForUser(ctx, user.ID, "movies:write"); err != nil {
+				t.Fatal(err)
+			}
+			permissions, err := m.Permission.GetAllForUser(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !permissions.Includes("movies:read") || permissions.Includes("movies:write") || len(permissions) != 1 {
+				t.Errorf("got %v, want [movies:read]", permissions)
+			}
+		})
+	}
+}
+
+func TestPermissionRepository(t *testing.T) {
+	ctx := context.Background()
+	for backend, m := range backends(t) {
+		t.Run(backend, func(t *testing.T) {
+			user := createUser(t, m, "permissions")
+			if err := m.Permission.AddForUser(ctx, user.ID, "movies:read", "movies:write"); err != nil {
+				t.Fatal(err)
+			}
+			// The primary key of users_permissions rejects a permission granted twice
+			if err := m.Permission.AddForUser(ctx, user.ID, "movies:read"); err == nil {
+				t.Error("granting a permission twice succeeded, want an error")
+			}
+
+			permissions, err := m.Permission.GetAllForUser(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !permissions.Includes("movies:read") || !permissions.Includes("movies:write") || len(permissions) != 2 {
+				t.Errorf("got %v, want [movies:read movies:write]", permissions)
+			}
+		})
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+	for backend, m := range backends(t) {
+		t.Run(backend+"/rolled back on error", func(t *testing.T) {
+			movie := createMovie(t, m)
+			var user *data.User
+			err := m.WithTx(ctx, func(tx Models) error {
+				user = createUser(t, tx, "rolled back")
+				movie.Title = "Contract Test Rolled Back"
+				if err := tx.Movie.Update(ctx, movie); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("error = %v, want the error of fn", err)
+			}
+			if _, err := m.User.GetByEmail(ctx, user.Email); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("GetByEmail error = %v, want ErrRecordNotFound", err)
+			}
+			got, err := m.Movie.Get(ctx, movie.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != "Contract Test" || got.Version != 1 {
+				t.Errorf("got %q version %d, want the movie as it was before the transaction", got.Title, got.Version)
+			}
+		})
+
+		t.Run(backend+"/committed", func(t *testing.T) {
+			var user *data.User
+			if err := m.WithTx(ctx, func(tx Models) error {
+				user = createUser(t, tx, "committed")
+				return tx.Permission.AddForUser(ctx, user.ID, "movies:read")
+			}); err != nil {
+				t.Fatal(err)
+			}
+			permissions, err := m.Permission.GetAllForUser(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !permissions.Includes("movies:read") {
+				t.Errorf("got %v, want [movies:read]", permissions)
+			}
+		})
+	}
+}
+
+func TestGenreRepository(t *testing.T) {
+	ctx := context.Background()
+	for backend, m := range backends(t) {
+		t.Run(backend, func(t *testing.T) {
+			slug := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+			genre := &data.Genre{Slug: slug, Name: "Contract"}
+			if err := m.Genre.Create(ctx, genre); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Genre.Create(ctx, &data.Genre{Slug: slug, Name: "Other"}); !errors.Is(err, ErrDuplicateGenre) {
+				t.Errorf("duplicate Create error = %v, want ErrDuplicateGenre", err)
+			}
+
+			movie := &data.Movie{Title: "Contract Test", Year: 2001, Runtime: 100, Genres: []string{"drama", slug}}
+			if err := m.Movie.Create(ctx, movie); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Genre.Delete(ctx, genre.ID); !errors.Is(err, ErrGenreInUse) {
+				t.Errorf("Delete error = %v, want ErrGenreInUse", err)
+			}
+
+			// Renaming the slug renames the genre of the movies
+			genre.Slug = slug + "-renamed"
+			if err := m.Genre.Update(ctx, genre, slug); err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Movie.Get(ctx, movie.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slicesEqual(got.Genres, []string{"drama", genre.Slug}) || got.Version != 2 {
+				t.Errorf("got genres %v version %d, want [drama %s] version 2", got.Genres, got.Version, genre.Slug)
+			}
+			index, err := m.Genre.Index(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := index.Resolve(genre.Slug); !ok {
+				t.Errorf("index does not resolve %s", genre.Slug)
+			}
+		})
+	}
+}
+
+// memberPositions returns the movies of the collection by position
+func memberPositions(t *testing.T, m Models, collectionID int64) []int64 {
+	t.Helper()
+	members, err := m.Collection.GetMembers(context.Background(), collectionID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movieIDs := make([]int64, 0, len(members))
+	for i, member := range members {
+		if member.Position != int32(i+1) {
+			t.Fatalf("got positions %+v, want them to run from 1", members)
+		}
+		movieIDs = append(movieIDs, member.MovieID)
+	}
+	return movieIDs
+}
+
+func TestCollectionRepository(t *testing.T) {
+	ctx := context.Background()
+	for backend, m := range backends(t) {
+		t.Run(backend, func(t *testing.T) {
+			collection := &data.Collection{Name: "Contract Collection"}
+			if err := m.Collection.Create(ctx, collection); err != nil {
+				t.Fatal(err)
+			}
+			a, b, c := createMovie(t, m), createMovie(t, m), createMovie(t, m)
+			for _, member := range []data.CollectionMember{{MovieID: a.ID}, {MovieID: b.ID}, {MovieID: c.ID, Position: 1}} {
+				member.CollectionID = collection.ID
+				if err := m.Collection.AddMovie(ctx, &member); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := memberPositions(t, m, collection.ID); !slices.Equal(got, []int64{c.ID, a.ID, b.ID}) {
+				t.Errorf("after AddMovie got %v, want %v", got, []int64{c.ID, a.ID, b.ID})
+			}
+			if err := m.Collection.AddMovie(ctx, &data.CollectionMember{CollectionID: collection.ID, MovieID: a.ID}); !errors.Is(err, ErrDuplicateCollectionMovie) {
+				t.Errorf("duplicate AddMovie error = %v, want ErrDuplicateCollectionMovie", err)
+			}
+
+			if err := m.Collection.MoveMovie(ctx, &data.CollectionMember{CollectionID: collection.ID, MovieID: c.ID}); err != nil {
+				t.Fatal(err)
+			}
+			if got := memberPositions(t, m, collection.ID); !slices.Equal(got, []int64{a.ID, b.ID, c.ID}) {
+				t.Errorf("after MoveMovie got %v, want %v", got, []int64{a.ID, b.ID, c.ID})
+			}
+
+			// Deleting a movie closes the gap it leaves
+			if err := m.Movie.Delete(ctx, a.ID); err != nil {
+				t.Fatal(err)
+			}
+			if got := memberPositions(t, m, collection.ID); !slices.Equal(got, []int64{b.ID, c.ID}) {
+				t.Errorf("after Delete got %v, want %v", got, []int64{b.ID, c.ID})
+			}
+
+			if err := m.Collection.RemoveMovie(ctx, collection.ID, b.ID); err != nil {
+				t.Fatal(err)
+			}
+			if got := memberPositions(t, m, collection.ID); !slices.Equal(got, []int64{c.ID}) {
+				t.Errorf("after RemoveMovie got %v, want %v", got, []int64{c.ID})
+			}
+			if err := m.Collection.RemoveMovie(ctx, collection.ID, b.ID); !errors.Is(err, ErrRecordNotFound) {
+				t.Errorf("second RemoveMovie error = %v, want ErrRecordNotFound", err)
+			}
+		})
+	}
+}
+
+func TestMergeRepository(t *testing.T) {
+	ctx := context.Background()
+	for backend, m := range backends(t) {
+		t.Run(backend, func(t *testing.T) {
+			source, target := createMovie(t, m), createMovie(t, m)
+			tmdbID := strconv.FormatInt(time.Now().UnixNano(), 10)
+			rows := []error{
+				m.ExternalID.Put(ctx, &data.ExternalID{MovieID: source.ID, Source: data.ExternalSourceTMDb, ID: tmdbID}),
+				m.Certification.Put(ctx, &data.Certification{MovieID: source.ID, Country: "US", Code: "R"}),
+				m.Certification.Put(ctx, &data.Certification{MovieID: source.ID, Country: "GB", Code: "15"}),
+				m.Certification.Put(ctx, &data.Certification{MovieID: target.ID, Country: "US", Code: "PG"}),
+			}
+			if err := errors.Join(rows...); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.ExternalID.Put(ctx, &data.ExternalID{MovieID: target.ID, Source: data.ExternalSourceTMDb, ID: tmdbID}); !errors.Is(err, ErrDuplicateExternalID) {
+				t.Errorf("duplicate Put error = %v, want ErrDuplicateExternalID", err)
+			}
+
+			if _, err := m.Movie.Merge(ctx, source.ID, target.ID); err != nil {
+				t.Fatal(err)
+			}
+			id, err := m.ExternalID.GetMovieID(ctx, data.ExternalSourceTMDb, tmdbID)
+			if err != nil || id != target.ID {
+				t.Errorf("GetMovieID = %d, %v, want %d", id, err, target.ID)
+			}
+			// The certification of the target is kept, the one it lacks is moved
+			certifications, err := m.Certification.GetAllForMovies(ctx, []int64{target.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []data.Certification{{MovieID: target.ID, Country: "GB", Code: "15"}, {MovieID: target.ID, Country: "US", Code: "PG"}}
+			if !slices.Equal(certifications[target.ID], want) {
+				t.Errorf("got certifications %+v, want %+v", certifications[target.ID], want)
+			}
+		})
+	}
+}
+
+func slicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nguyenanhhao221/greenlight-api/internal/data"
+)
+
+type ReviewModel struct {
+	DB *DB
+}
+
+// Create inserts the review and adds its rating to the movie aggregates in the same transaction.
+// ErrDuplicateReview is returned if the user already reviewed the movie.
+func (m ReviewModel) Create(ctx context.Context, review *data.Review) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback(ctxWithTimeout)
+
+	query := `
+		INSERT INTO reviews (movie_id, user_id, rating, body)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM movies WHERE id = $1 AND deleted_at IS NULL)
+		RETURNING id, created_at, updated_at, version;
+	`
+	args := []any{review.MovieID, review.UserID, review.Rating, review.Body}
+	err = tx.QueryRow(ctxWithTimeout, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		// No row is inserted for a missing or merged movie
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505": // unique_violation
+				if pgErr.ConstraintName == "reviews_movie_id_user_id_key" {
+					return ErrDuplicateReview
+				}
+			case "23503": // foreign_key_violation
+				return ErrRecordNotFound
+			}
+		}
+		return fmt.Errorf("failed to create review: %w", err)
+	}
+
+	if err := m.adjustMovieRating(ctxWithTimeout, tx, review.MovieID, int64(review.Rating), 1); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// GetForUser returns the review the user wrote for the movie
+func (m ReviewModel) GetForUser(ctx context.Context, movieID, userID int64) (*data.Review, error) {
+	query := `
+	SELECT id, movie_id, user_id, rating, body, created_at, updated_at, version
+	FROM reviews
+	WHERE movie_id = $1 AND user_id = $2;
+	`
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	var review data.Review
+	err := m.DB.QueryRow(ctxWithTimeout, query, movieID, userID).Scan(
+		&review.ID,
+		&review.MovieID,
+		&review.UserID,
+		&review.Rating,
+		&review.Body,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetAllForMovie returns a page of the reviews written for a movie
+func (m ReviewModel) GetAllForMovie(ctx context.Context, movieID int64, filters data.Filters) ([]data.Review, data.Metadata, error) {
+	orderBy, err := filters.OrderBy()
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER() AS count, reviews.id, reviews.movie_id, reviews.user_id, users.name,
+			reviews.rating, reviews.body, reviews.created_at, reviews.updated_at, reviews.version
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE reviews.movie_id = $1
+		ORDER BY %s, reviews.id ASC
+		LIMIT $2 OFFSET $3;`,
+		orderBy,
+	)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.Query(ctx, query, movieID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, data.Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := make([]data.Review, 0)
+	for rows.Next() {
+		var review data.Review
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.MovieID,
+			&review.UserID,
+			&review.UserName,
+			&review.Rating,
+			&review.Body,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, data.Metadata{}, err
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, data.Metadata{}, err
+	}
+
+	return reviews, data.CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Update saves the new rating and body of the review, the movie aggregates are adjusted by the
+// difference with the previous rating in the same transaction.
+func (m ReviewModel) Update(ctx context.Context, review *data.Review) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	// Lock the review so that the previous rating can't change before the aggregates are adjusted
+	var previousRating int16
+	err = tx.QueryRow(ctxWithTimeout, `SELECT rating FROM reviews WHERE id = $1 FOR UPDATE;`, review.ID).Scan(&previousRating)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	// Use version to prevent data race condition to update. This can be consider as optimistic locking
+	query := `
+		UPDATE reviews
+		SET rating = $1, body = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING updated_at, version;
+	`
+	args := []any{review.Rating, review.Body, review.ID, review.Version}
+	if err := tx.QueryRow(ctxWithTimeout, query, args...).Scan(&review.UpdatedAt, &review.Version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	if err := m.adjustMovieRating(ctxWithTimeout, tx, review.MovieID, int64(review.Rating-previousRating), 0); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// Delete removes the review of the user for the movie and takes its rating out of the movie aggregates
+func (m ReviewModel) Delete(ctx context.Context, movieID, userID int64) error {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.Begin(ctxWithTimeout)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	var rating int16
+	query := `
+		DELETE FROM reviews
+		WHERE movie_id = $1 AND user_id = $2
+		RETURNING rating;
+	`
+	if err := tx.QueryRow(ctxWithTimeout, query, movieID, userID).Scan(&rating); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	if err := m.adjustMovieRating(ctxWithTimeout, tx, movieID, -int64(rating), -1); err != nil {
+		return err
+	}
+	return tx.Commit(ctxWithTimeout)
+}
+
+// adjustMovieRating incrementally updates the rating aggregates of a movie. The movie version is left
+// untouched since a new review is not an edit of the movie itself.
+func (m ReviewModel) adjustMovieRating(ctx context.Context, tx pgx.Tx, movieID int64, sumDelta int64, countDelta int) error {
+	query := `
+		UPDATE movies
+		SET rating_sum = rating_sum + $1, rating_count = rating_count + $2
+		WHERE id = $3;
+	`
+	if _, err := tx.Exec(ctx, query, sumDelta, countDelta, movieID); err != nil {
+		return fmt.Errorf("error adjusting movie rating %w", err)
+	}
+	return nil
+}
@@ -9,12 +9,11 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 )
 
 type TokenModel struct {
-	DB *pgxpool.Pool
+	DB *DB
 }
 
 func (m TokenModel) generateToken() (string, []byte) {
@@ -24,32 +23,32 @@ func (m TokenModel) generateToken() (string, []byte) {
 }
 
 // New Generate an activation token for the newly created user and insert into token table
-func (m TokenModel) New(userId int64, ttl time.Duration, scope string) (*data.Token, error) {
+func (m TokenModel) New(ctx context.Context, userId int64, ttl time.Duration, scope string) (*data.Token, error) {
 	plain, hash := m.generateToken()
 	expiry := time.Now().Add(ttl)
 
 	token := &data.Token{UserID: userId, Plain: plain, Hash: hash, Expiry: expiry, Scope: scope}
-	if err := m.create(token); err != nil {
+	if err := m.create(ctx, token); err != nil {
 		return nil, fmt.Errorf("m.create token error :%w", err)
 	}
 	return token, nil
 }
 
-func (m TokenModel) create(token *data.Token) error {
+func (m TokenModel) create(ctx context.Context, token *data.Token) error {
 	query := `
 		INSERT INTO tokens (hash, user_id, expiry, scope)
 		VALUES ($1, $2, $3, $4);
 	`
 	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
 	_, err := m.DB.Exec(ctxWithTimeout, query, args...)
 	return err
 }
 
-func (m TokenModel) GetByUserId(userId int64) (*data.Token, error) {
+func (m TokenModel) GetByUserId(ctx context.Context, userId int64) (*data.Token, error) {
 	if userId < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -57,7 +56,7 @@ func (m TokenModel) GetByUserId(userId int64) (*data.Token, error) {
 	SELECT hash, user_id, expiry, scope FROM tokens
 	WHERE user_id=$1;
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 	token := data.Token{}
 	err := m.DB.QueryRow(ctx, query, userId).Scan(&token.Hash, &token.UserID, &token.Expiry, &token.Scope)
@@ -66,3 +65,32 @@ func (m TokenModel) GetByUserId(userId int64) (*data.Token, error) {
 	}
 	return &token, err
 }
+
+// DeleteAllForUser deletes every token of the user whatever its scope, which signs the user out. It returns
+// the number of tokens deleted.
+func (m TokenModel) DeleteAllForUser(ctx context.Context, userId int64) (int64, error) {
+	query := `DELETE FROM tokens WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tag, err := m.DB.Exec(ctx, query, userId)
+	if err != nil {
+		return 0, fmt.Errorf("error DeleteAllForUser %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteExpired deletes the expired tokens of every user and returns how many were deleted
+func (m TokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM tokens WHERE expiry <= $1`
+
+	ctx, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
+	defer cancel()
+
+	tag, err := m.DB.Exec(ctx, query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error DeleteExpired %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -11,16 +11,15 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nguyenanhhao221/greenlight-api/internal/data"
 )
 
 type UserModel struct {
-	DB *pgxpool.Pool
+	DB *DB
 }
 
 // Create inserts a new user record into the database
-func (m UserModel) Create(user *data.User) error {
+func (m UserModel) Create(ctx context.Context, user *data.User) error {
 	query := `
 		INSERT INTO users (name, email, password_hash, activated)
 		VALUES ($1, $2, $3, $4)
@@ -28,7 +27,7 @@ func (m UserModel) Create(user *data.User) error {
 	`
 	args := []any{user.Name, user.Email, user.Password.Hash, user.Activated}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
@@ -47,11 +46,12 @@ func (m UserModel) Create(user *data.User) error {
 	return nil
 }
 
-func (m UserModel) GetUserWithToken(plainToken string, scope string) (*data.User, error) {
+func (m UserModel) GetUserWithToken(ctx context.Context, plainToken string, scope string) (*data.User, error) {
 	slog.Info("GetUserWithToken", "scope", scope)
 	tokenHash := sha256.Sum256([]byte(plainToken))
 	query := `
-	SELECT users.id, users.name, users.email, users.activated, users.created_at, users.version
+	SELECT users.id, users.name, users.email, users.activated, users.created_at, users.version,
+		users.max_certification_country, users.max_certification
 	FROM  users
 	INNER JOIN tokens
 	ON users.id = tokens.user_id
@@ -59,50 +59,60 @@ func (m UserModel) GetUserWithToken(plainToken string, scope string) (*data.User
 	AND tokens.scope = $2
 	AND tokens.expiry > $3`
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 	user := data.User{}
 	args := []any{tokenHash[:], scope, time.Now()}
-	err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&user.ID, &user.Name, &user.Email, &user.Activated, &user.CreatedAt, &user.Version)
+	var maxCountry, maxCode *string
+	err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&user.ID, &user.Name, &user.Email, &user.Activated, &user.CreatedAt, &user.Version, &maxCountry, &maxCode)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrRecordNotFound
 		}
 		return nil, err
 	}
+	user.MaxRating = maxRating(maxCountry, maxCode)
 	slog.Info("Successfully get user with provided token")
 	return &user, nil
 }
 
-func (m UserModel) Update(user *data.User) error {
+func (m UserModel) Update(ctx context.Context, user *data.User) error {
 	slog.Info("Update user to activate in db")
 	// Use version to prevent data race condition to update. This can be consider as optimistic locking
 	query := `
 		UPDATE users 
-		SET name = $1, email = $2, activated = $3, version = version + 1
-		WHERE id = $4 AND version = $5
+		SET name = $1, email = $2, activated = $3, max_certification_country = $4, max_certification = $5, version = version + 1
+		WHERE id = $6 AND version = $7
 		RETURNING version;
 	`
-	args := []any{user.Name, user.Email, user.Activated, user.ID, user.Version}
+	var maxCountry, maxCode *string
+	if user.MaxRating != nil {
+		maxCountry, maxCode = &user.MaxRating.Country, &user.MaxRating.Code
+	}
+	args := []any{user.Name, user.Email, user.Activated, maxCountry, maxCode, user.ID, user.Version}
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 	if err := m.DB.QueryRow(ctxWithTimeout, query, args...).Scan(&user.Version); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrEditConflict
 		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_email_key" {
+			return fmt.Errorf("%w: %s", ErrDuplicateEmail, pgErr.Detail)
+		}
 		return fmt.Errorf("error update user to db: %w", err)
 	}
 	return nil
 }
 
-func (m UserModel) DeleteAllTokenForUser(scope string, userId int64) error {
+func (m UserModel) DeleteAllTokenForUser(ctx context.Context, scope string, userId int64) error {
 	query := `
 	DELETE FROM tokens
 	WHERE scope = $1
 	AND user_id = $2;
 	`
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
 	if _, err := m.DB.Exec(ctxWithTimeout, query, scope, userId); err != nil {
@@ -112,16 +122,17 @@ func (m UserModel) DeleteAllTokenForUser(scope string, userId int64) error {
 }
 
 // GetByEmail retrieves a user record by email address
-func (m UserModel) GetByEmail(email string) (*data.User, error) {
+func (m UserModel) GetByEmail(ctx context.Context, email string) (*data.User, error) {
 	query := `
-	SELECT id, created_at, name, email, password_hash, activated, version 
+	SELECT id, created_at, name, email, password_hash, activated, version, max_certification_country, max_certification
 	FROM users
 	WHERE email = $1
 	`
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, m.DB.QueryTimeout)
 	defer cancel()
 
 	var user data.User
+	var maxCountry, maxCode *string
 
 	err := m.DB.QueryRow(ctxWithTimeout, query, email).Scan(
 		&user.ID,
@@ -131,6 +142,8 @@ func (m UserModel) GetByEmail(email string) (*data.User, error) {
 		&user.Password.Hash,
 		&user.Activated,
 		&user.Version,
+		&maxCountry,
+		&maxCode,
 	)
 	if err != nil {
 		switch {
@@ -140,5 +153,14 @@ func (m UserModel) GetByEmail(email string) (*data.User, error) {
 			return nil, fmt.Errorf("failed to get user by email: %w", err)
 		}
 	}
+	user.MaxRating = maxRating(maxCountry, maxCode)
 	return &user, nil
 }
+
+// maxRating builds the certification limit of a user from its nullable columns
+func maxRating(country, code *string) *data.Certification {
+	if country == nil || code == nil {
+		return nil
+	}
+	return &data.Certification{Country: *country, Code: *code}
+}
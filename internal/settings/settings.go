@@ -0,0 +1,133 @@
+// Package settings reads the settings shared by the commands of the repository from the config file and the
+// GREENLIGHT_* environment variables. The API layers its own defaults and flags around them, the other
+// commands only read the database DSN through [DSN].
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, e.g. GREENLIGHT_DB_DSN for db-dsn
+const EnvPrefix = "GREENLIGHT_"
+
+// DefaultDSN is the db-dsn setting when neither the config file nor the environment set it
+const DefaultDSN = "postgres://greenlight@localhost/greenlight"
+
+// EnvName returns the environment variable of a setting, e.g. GREENLIGHT_DB_MAX_OPEN_CONN for db-max-open-conn
+func EnvName(setting string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// ReadFile returns the settings of a YAML or TOML file, chosen by its extension, by setting name. The keys
+// are the names of the settings, nested tables are joined with a dash so that db: {max-open-conn: 25} sets
+// db-max-open-conn, underscores may replace dashes. Lists are joined with spaces.
+func ReadFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	values := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return nil, fmt.Errorf("config file: unsupported extension %q, must be .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return flatten("", values), nil
+}
+
+// flatten returns the values of the decoded config file by setting name
+func flatten(prefix string, values map[string]any) map[string]string {
+	flat := make(map[string]string)
+	for key, value := range values {
+		name := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		if prefix != "" {
+			name = prefix + "-" + name
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			for nested, nestedValue := range flatten(name, value) {
+				flat[nested] = nestedValue
+			}
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			flat[name] = strings.Join(items, " ")
+		case nil:
+			flat[name] = ""
+		default:
+			flat[name] = fmt.Sprint(value)
+		}
+	}
+	return flat
+}
+
+// SecretEnv returns the value of a secret setting from its environment variable, or from the file named
+// by its _FILE variable instead, e.g. GREENLIGHT_SMTP_PASSWORD_FILE. The source is the variable the value
+// was read from, it is empty when neither is set.
+func SecretEnv(getenv func(string) string, setting string) (value, source string, err error) {
+	key := EnvName(setting)
+	value = getenv(key)
+	path := getenv(key + "_FILE")
+	switch {
+	case path != "" && value != "":
+		return "", "", fmt.Errorf("%s and %s_FILE are both set, only one of them is allowed", key, key)
+	case path != "":
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		// Files written by editors or secret managers usually end with a newline
+		return strings.TrimRight(string(content), "\r\n"), key + "_FILE", nil
+	case value != "":
+		return value, key, nil
+	default:
+		return "", "", nil
+	}
+}
+
+// DSN returns the db-dsn setting as the API resolves it: the environment overrides the config file, which
+// overrides [DefaultDSN]. An empty file uses the one of GREENLIGHT_CONFIG, if any. The other settings of the
+// file are not validated, the commands sharing the config file of the API ignore them.
+func DSN(file string, getenv func(string) string) (string, error) {
+	dsn := DefaultDSN
+	if file == "" {
+		file = getenv(EnvPrefix + "CONFIG")
+	}
+	if file != "" {
+		settings, err := ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("invalid configuration:\n\t%w", err)
+		}
+		if value, ok := settings["db-dsn"]; ok {
+			dsn = value
+		}
+	}
+
+	value, source, err := SecretEnv(getenv, "db-dsn")
+	if err != nil {
+		return "", fmt.Errorf("invalid configuration:\n\t%w", err)
+	}
+	if source != "" {
+		dsn = value
+	}
+	if dsn == "" {
+		return "", errors.New("invalid configuration:\n\tdb-dsn: must be provided")
+	}
+	return dsn, nil
+}
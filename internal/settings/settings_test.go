@@ -0,0 +1,85 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	want := map[string]string{
+		"port":                 "4000",
+		"db-max-open-conn":     "25",
+		"cors-trusted-origins": "https://a.example.com https://b.example.com",
+		"smtp-sender":          "",
+	}
+	files := map[string]string{
+		"config.yaml": "port: 4000\ndb:\n  max_open_conn: 25\ncors:\n  trusted-origins: [https://a.example.com, https://b.example.com]\nsmtp-sender:\n",
+		"config.toml": "port = 4000\nsmtp-sender = \"\"\ncors-trusted-origins = [\"https://a.example.com\", \"https://b.example.com\"]\n[db]\nmax-open-conn = 25\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			got, err := ReadFile(writeFile(t, filepath.Join(dir, name), content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			for setting, value := range want {
+				if got[setting] != value {
+					t.Errorf("%s: got %q, want %q", setting, got[setting], value)
+				}
+			}
+		})
+	}
+
+	if _, err := ReadFile(writeFile(t, filepath.Join(dir, "config.json"), "{}")); err == nil {
+		t.Error("reading a .json file succeeded, want an unsupported extension error")
+	}
+}
+
+func TestDSN(t *testing.T) {
+	dir := t.TempDir()
+	// The config file of the API holds settings the other commands don't know about
+	configFile := writeFile(t, filepath.Join(dir, "config.yaml"), "port: 4000\ndb:\n  dsn: postgres://file@localhost/greenlight\n  max-open-conn: 25\n")
+	emptyFile := writeFile(t, filepath.Join(dir, "empty.yaml"), "db:\n  dsn: \"\"\n")
+	secretFile := writeFile(t, filepath.Join(dir, "dsn"), "postgres://secret@localhost/greenlight\n")
+
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", nil, DefaultDSN, false},
+		{"config file", configFile, nil, "postgres://file@localhost/greenlight", false},
+		{"config file from the environment", "", map[string]string{"GREENLIGHT_CONFIG": configFile}, "postgres://file@localhost/greenlight", false},
+		{"environment over the file", configFile, map[string]string{"GREENLIGHT_DB_DSN": "postgres://env@localhost/greenlight"}, "postgres://env@localhost/greenlight", false},
+		{"secret file", "", map[string]string{"GREENLIGHT_DB_DSN_FILE": secretFile}, "postgres://secret@localhost/greenlight", false},
+		{"both the variable and the secret file", "", map[string]string{"GREENLIGHT_DB_DSN": "postgres://env@localhost/greenlight", "GREENLIGHT_DB_DSN_FILE": secretFile}, "", true},
+		{"missing config file", filepath.Join(dir, "missing.yaml"), nil, "", true},
+		{"empty DSN", emptyFile, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DSN(tt.file, func(key string) string { return tt.env[key] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
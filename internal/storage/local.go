@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores the objects as files in a directory of the local filesystem
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal creates the directory if needed and returns a [Local] storage. Objects are served under
+// baseURL, e.g. baseURL "/v1/images" gives the URL "/v1/images/<key>".
+func NewLocal(dir, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating storage directory: %w", err)
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Put writes to a temporary file first and renames it, so that a partially written object is never served
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file is a no-op once it has been renamed
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(l.dir, key))
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error) {
+	if !ValidKey(key) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(l.dir, key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ObjectInfo{}, ErrNotFound
+		}
+		return nil, ObjectInfo{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return f, ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(l.dir, key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.baseURL + "/" + key
+}
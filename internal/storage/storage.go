@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// keyRX restricts the object keys to a flat namespace of simple file names, so that a key can never
+// escape the storage location of any backend
+var keyRX = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,254}$`)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is implemented by every backend able to store uploaded files such as movie images
+type Storage interface {
+	// Put stores the content of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the content of the object, the caller must close it. ErrNotFound is returned if the key does not exist
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error)
+	// Delete removes the object, deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL where the object can be downloaded
+	URL(key string) string
+}
+
+// ValidKey reports whether the key can be used with any [Storage] backend
+func ValidKey(key string) bool {
+	return keyRX.MatchString(key) && key != "." && key != ".."
+}
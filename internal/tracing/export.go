@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bufferedLines buffers the lines written by an exporter, so that exporting a span on the request path
+// never waits for the output. The lines are flushed every second and on close.
+type bufferedLines struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer // Closed with the lines, nil when the exporter doesn't own the output
+	closed bool
+}
+
+func newBufferedLines(w io.Writer, closer io.Closer) *bufferedLines {
+	b := &bufferedLines{w: bufio.NewWriter(w), closer: closer}
+	go b.flushPeriodically()
+	return b
+}
+
+func (b *bufferedLines) flushPeriodically() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+		b.w.Flush()
+		b.mu.Unlock()
+	}
+}
+
+// write buffers the encoded line, which ends with a newline
+func (b *bufferedLines) write(line []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return os.ErrClosed
+	}
+	_, err := b.w.Write(line)
+	return err
+}
+
+// close flushes the lines, the calls after the first one are no-ops
+func (b *bufferedLines) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	err := b.w.Flush()
+	if b.closer != nil {
+		if closeErr := b.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// encodeLine encodes v as a line of JSON, outside of the lock of the output
+func encodeLine(v any) ([]byte, error) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// StdoutExporter writes every span as a line of JSON meant to be read by humans
+type StdoutExporter struct {
+	lines *bufferedLines
+}
+
+// NewStdoutExporter returns an exporter writing to w, os.Stdout when nil. The spans are flushed every second
+// and on Shutdown.
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &StdoutExporter{lines: newBufferedLines(w, nil)}
+}
+
+func (e *StdoutExporter) ExportSpan(span SpanData) error {
+	attributes := make(map[string]any, len(span.Attributes))
+	for _, attribute := range span.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	line := struct {
+		Name         string         `json:"name"`
+		Kind         string         `json:"kind"`
+		TraceID      string         `json:"trace_id"`
+		SpanID       string         `json:"span_id"`
+		ParentSpanID string         `json:"parent_span_id,omitempty"`
+		Start        time.Time      `json:"start"`
+		Duration     string         `json:"duration"`
+		Attributes   map[string]any `json:"attributes,omitempty"`
+		Error        string         `json:"error,omitempty"`
+	}{
+		Name:       span.Name,
+		Kind:       span.Kind.String(),
+		TraceID:    span.SpanContext.TraceID.String(),
+		SpanID:     span.SpanContext.SpanID.String(),
+		Start:      span.Start,
+		Duration:   span.End.Sub(span.Start).String(),
+		Attributes: attributes,
+		Error:      span.StatusMessage,
+	}
+	if span.ParentSpanID.IsValid() {
+		line.ParentSpanID = span.ParentSpanID.String()
+	}
+
+	encoded, err := encodeLine(line)
+	if err != nil {
+		return err
+	}
+	return e.lines.write(encoded)
+}
+
+// Shutdown flushes the spans, the writer is left open
+func (e *StdoutExporter) Shutdown(ctx context.Context) error {
+	return e.lines.close()
+}
+
+// OTLPFileExporter appends the spans to a file in the OTLP JSON encoding, one ExportTraceServiceRequest per
+// line, which the file receiver of the OpenTelemetry Collector and most trace viewers can import, see
+// https://opentelemetry.io/docs/specs/otel/protocol/file-exporter/
+type OTLPFileExporter struct {
+	serviceName string
+	lines       *bufferedLines
+}
+
+// NewOTLPFileExporter opens the file for appending, the spans are flushed to it every second and on Shutdown
+func NewOTLPFileExporter(path, serviceName string) (*OTLPFileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &OTLPFileExporter{serviceName: serviceName, lines: newBufferedLines(file, file)}, nil
+}
+
+// otlpValue is an AnyValue of OTLP, the 64 bits integers are encoded as strings in JSON
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func otlpAttributes(attributes []Attribute) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value otlpValue
+		switch v := attribute.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpAttribute{Key: attribute.Key, Value: value})
+	}
+	return encoded
+}
+
+// otlpSpanKind maps the kinds to the SpanKind enum of OTLP
+var otlpSpanKind = map[SpanKind]int{SpanKindInternal: 1, SpanKindServer: 2, SpanKindClient: 3}
+
+func (e *OTLPFileExporter) ExportSpan(span SpanData) error {
+	type otlpStatus struct {
+		Code    int    `json:"code"` // 1 for ok, 2 for error
+		Message string `json:"message,omitempty"`
+	}
+	type otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	encoded := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		Name:              span.Name,
+		Kind:              otlpSpanKind[span.Kind],
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		Attributes:        otlpAttributes(span.Attributes),
+		Status:            otlpStatus{Code: 1},
+	}
+	if span.ParentSpanID.IsValid() {
+		encoded.ParentSpanID = span.ParentSpanID.String()
+	}
+	if span.Error {
+		encoded.Status = otlpStatus{Code: 2, Message: span.StatusMessage}
+	}
+
+	request := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes([]Attribute{Attr("service.name", e.serviceName)})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/nguyenanhhao221/greenlight-api/internal/tracing"},
+				"spans": []otlpSpan{encoded},
+			}},
+		}},
+	}
+
+	line, err := encodeLine(request)
+	if err != nil {
+		return err
+	}
+	return e.lines.write(line)
+}
+
+func (e *OTLPFileExporter) Shutdown(ctx context.Context) error {
+	return e.lines.close()
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// testSpan is a span with an attribute of every supported type, its IDs and times are fixed
+func testSpan() SpanData {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return SpanData{
+		Name:         "GET /v1/movies/:id",
+		Kind:         SpanKindServer,
+		SpanContext:  SpanContext{TraceID: TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}, SpanID: SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}, Sampled: true},
+		ParentSpanID: SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		Start:        start,
+		End:          start.Add(1500 * time.Microsecond),
+		Attributes: []Attribute{
+			Attr("http.route", "/v1/movies/:id"),
+			Attr("http.response.status_code", 500),
+			Attr("db.response.rows_affected", int64(1)),
+			Attr("cached", false),
+			Attr("ratio", 0.25),
+			Attr("duration", time.Second),
+		},
+		Error:         true,
+		StatusMessage: "500 Internal Server Error",
+	}
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := NewOTLPFileExporter(path, "greenlight-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := testSpan()
+	if err := exporter.ExportSpan(span); err != nil {
+		t.Fatal(err)
+	}
+	span.Error, span.StatusMessage, span.ParentSpanID, span.Attributes = false, "", SpanID{}, nil
+	if err := exporter.ExportSpan(span); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.ExportSpan(span); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ExportSpan after Shutdown error = %v, want os.ErrClosed", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "spans.otlp.jsonl")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var out bytes.Buffer
+	exporter := NewStdoutExporter(&out)
+	if err := exporter.ExportSpan(testSpan()); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var line struct {
+		Name         string         `json:"name"`
+		Kind         string         `json:"kind"`
+		TraceID      string         `json:"trace_id"`
+		ParentSpanID string         `json:"parent_span_id"`
+		Duration     string         `json:"duration"`
+		Attributes   map[string]any `json:"attributes"`
+		Error        string         `json:"error"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("decoding %s: %v", out.String(), err)
+	}
+	if line.Name != "GET /v1/movies/:id" || line.Kind != "server" || line.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		line.ParentSpanID != "0102030405060708" || line.Duration != "1.5ms" || line.Error != "500 Internal Server Error" ||
+		line.Attributes["http.route"] != "/v1/movies/:id" {
+		t.Errorf("unexpected line %s", out.String())
+	}
+}
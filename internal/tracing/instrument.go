@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// logHandler adds the trace_id and span_id of the context to the records, so that the logs of a request
+// can be found from its trace and the other way around
+type logHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h, only the records logged with a context (e.g. [slog.Logger.InfoContext]) carry the IDs
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
+
+// QueryTracer is a [pgx.QueryTracer] recording a span for every query run within a trace
+type QueryTracer struct{}
+
+// querySpanKey holds the span of the query, it is not the span of the context so that a query which is not
+// traced never ends the span of its caller
+type querySpanKey struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, _, _ := strings.Cut(strings.TrimSpace(data.SQL), " ")
+	_, span := Start(ctx, "db "+strings.ToUpper(operation), SpanKindClient,
+		Attr("db.system.name", "postgresql"),
+		Attr("db.query.text", strings.Join(strings.Fields(data.SQL), " ")),
+	)
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey{}).(*Span)
+	if !ok {
+		return
+	}
+	// Not finding a row is an answer rather than a failure of the query
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		span.SetError(data.Err)
+	}
+	span.SetAttributes(Attr("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	span.End()
+}
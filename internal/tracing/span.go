@@ -0,0 +1,162 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SpanKind tells the role of the span in the trace, as in OpenTelemetry
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer            // Handles a request of another service
+	SpanKindClient            // Calls another service, such as the database or the SMTP server
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Attribute is a key value pair describing a span, the keys follow the OpenTelemetry semantic conventions
+// where there is one, e.g. http.route
+type Attribute struct {
+	Key   string
+	Value any // string, bool, int, int64 or float64, anything else is exported formatted
+}
+
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is an ended span, as given to the [Exporter]
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID // Invalid for the root span of the trace
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Error         bool
+	StatusMessage string
+}
+
+// Exporter sends the ended spans somewhere they can be inspected, ExportSpan may be called concurrently
+type Exporter interface {
+	ExportSpan(span SpanData) error
+	// Shutdown flushes the spans not exported yet and releases the exporter
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer starts the spans and exports them once they end
+type Tracer struct {
+	exporter Exporter // nil to create the spans without exporting them, they still carry the trace IDs
+}
+
+// NewTracer returns a tracer exporting to exporter, which may be nil
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start starts a span, the child of the span of ctx or of its remote span context, and returns a copy of
+// ctx holding it. Without a parent a new trace is started, and sampled.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	span := &Span{tracer: t, data: SpanData{Name: name, Kind: kind, Start: time.Now(), Attributes: attributes}}
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.data.SpanContext = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		span.data.ParentSpanID = parent.SpanID
+	} else {
+		span.data.SpanContext = SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Shutdown flushes and releases the exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// Span times an operation. A nil span is valid, all of its methods are no-ops.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the IDs of the span, the zero value for a nil span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	// The span context never changes once the span is started
+	return s.data.SpanContext
+}
+
+// SetName renames the span, e.g. once the route of a request is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+// SetError marks the operation of the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = true
+	s.data.StatusMessage = err.Error()
+}
+
+// End ends the span and exports it when its trace is sampled, the calls after the first one are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer.exporter == nil || !data.SpanContext.Sampled {
+		return
+	}
+	if err := s.tracer.exporter.ExportSpan(data); err != nil {
+		slog.Warn("error exporting span", "span", data.Name, "err", err.Error())
+	}
+}
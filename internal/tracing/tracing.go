@@ -0,0 +1,163 @@
+// Package tracing records spans in the fashion of OpenTelemetry: a span times an operation, such as a HTTP
+// request or a query, and belongs to a trace which may have started in another service, see
+// https://www.w3.org/TR/trace-context/ for the traceparent header which carries it.
+//
+// The root span of a request is started with [Tracer.Start]. The code called by the request starts its own
+// spans with [Start], which finds the tracer through the span of the context, so that the instrumented
+// packages don't depend on the tracer. Without a span in the context [Start] returns a nil span, every
+// method of which is a no-op.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"strings"
+)
+
+// TraceID identifies a trace, all the spans of a request across services share it
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within its trace
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+// SpanContext is the part of a span propagated to the other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool // The spans of an unsampled trace are not exported
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// ErrInvalidTraceparent is returned for a traceparent header which doesn't follow the W3C format
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a W3C traceparent header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(header string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	// Later versions may append fields, the first four keep their meaning
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	// Only lowercase hex is allowed
+	if strings.ToLower(header) != header {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	return sc, nil
+}
+
+// Traceparent formats the span context as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+type contextKey int
+
+const (
+	spanContextKey contextKey = iota
+	remoteContextKey
+)
+
+// ContextWithSpan returns a copy of ctx holding the span, the spans started from it are its children
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey, span)
+}
+
+// SpanFromContext returns the span of ctx, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx holding the span context received from another service,
+// e.g. from a traceparent header, the root span started from ctx continues its trace
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey, sc)
+}
+
+// SpanContextFromContext returns the span context of the span of ctx, or the remote one if there is no span
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteContextKey).(SpanContext)
+	return sc
+}
+
+// Start starts a child of the span of ctx, with the same tracer. It returns ctx and a nil span when ctx
+// has no span, so that code running outside of a trace isn't traced.
+func Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, kind, attributes...)
+}
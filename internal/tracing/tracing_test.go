@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name        string
+		header      string
+		wantSampled bool
+		wantErr     bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, false},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", false, false},
+		{"other flags", "00-" + traceID + "-" + spanID + "-03", true, false},
+		{"surrounding spaces", " 00-" + traceID + "-" + spanID + "-01 ", true, false},
+		{"later version", "01-" + traceID + "-" + spanID + "-01", true, false},
+		{"later version with more fields", "cc-" + traceID + "-" + spanID + "-01-what-the-future-holds", true, false},
+		{"empty", "", false, true},
+		{"version 00 with more fields", "00-" + traceID + "-" + spanID + "-01-extra", false, true},
+		{"forbidden version ff", "ff-" + traceID + "-" + spanID + "-01", false, true},
+		{"invalid version", "0x-" + traceID + "-" + spanID + "-01", false, true},
+		{"uppercase trace ID", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false, true},
+		{"uppercase flags", "00-" + traceID + "-" + spanID + "-0A", false, true},
+		{"all zero trace ID", "00-00000000000000000000000000000000-" + spanID + "-01", false, true},
+		{"all zero span ID", "00-" + traceID + "-0000000000000000-01", false, true},
+		{"short trace ID", "00-" + traceID[1:] + "-" + spanID + "-01", false, true},
+		{"long span ID", "00-" + traceID + "-" + spanID + "0-01", false, true},
+		{"not hex", "00-" + traceID[:31] + "g-" + spanID + "-01", false, true},
+		{"invalid flags", "00-" + traceID + "-" + spanID + "-0g", false, true},
+		{"missing field", "00-" + traceID + "-" + spanID, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.header)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTraceparent) {
+					t.Errorf("error = %v, want ErrInvalidTraceparent", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.wantSampled {
+				t.Errorf("got %s %s sampled %t, want %s %s sampled %t", sc.TraceID, sc.SpanID, sc.Sampled, traceID, spanID, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}
+		got, err := ParseTraceparent(sc.Traceparent())
+		if err != nil {
+			t.Fatalf("parsing %s: %v", sc.Traceparent(), err)
+		}
+		if got != sc {
+			t.Errorf("got %+v, want %+v", got, sc)
+		}
+	}
+}
+
+// recorder is an [Exporter] keeping the spans in memory
+type recorder struct {
+	spans []SpanData
+}
+
+func (r *recorder) ExportSpan(span SpanData) error {
+	r.spans = append(r.spans, span)
+	return nil
+}
+
+func (r *recorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestStartContinuesTheTrace(t *testing.T) {
+	exporter := &recorder{}
+	tracer := NewTracer(exporter)
+	remote, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, root := tracer.Start(ContextWithRemoteSpanContext(context.Background(), remote), "root", SpanKindServer)
+	_, child := Start(ctx, "child", SpanKindClient)
+	child.End()
+	root.End()
+	root.End()
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(exporter.spans))
+	}
+	childData, rootData := exporter.spans[0], exporter.spans[1]
+	if rootData.SpanContext.TraceID != remote.TraceID || rootData.ParentSpanID != remote.SpanID {
+		t.Errorf("the root span does not continue the remote trace: %+v", rootData)
+	}
+	if childData.SpanContext.TraceID != remote.TraceID || childData.ParentSpanID != rootData.SpanContext.SpanID {
+		t.Errorf("the child span is not a child of the root span: %+v", childData)
+	}
+
+	// Without a span in the context nothing is traced
+	if _, span := Start(context.Background(), "orphan", SpanKindInternal); span != nil {
+		t.Error("Start without a parent returned a span")
+	}
+}
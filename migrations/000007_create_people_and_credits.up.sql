@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS people (
+    id bigserial PRIMARY KEY,
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    biography text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people USING gin (
+    to_tsvector('simple', name)
+);
+
+-- A person can be credited several times on the same movie (e.g. both director and writer)
+CREATE TABLE IF NOT EXISTS movie_credits (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    person_id bigint NOT NULL REFERENCES people ON DELETE CASCADE,
+    role text NOT NULL,
+    character_name text NOT NULL DEFAULT '',
+    billing_order integer NOT NULL DEFAULT 0,
+    CONSTRAINT movie_credits_role_check CHECK (
+        role IN ('director', 'writer', 'actor')
+    ),
+    CONSTRAINT movie_credits_unique_key UNIQUE (
+        movie_id, person_id, role, character_name
+    )
+);
+
+CREATE INDEX IF NOT EXISTS movie_credits_movie_id_idx ON movie_credits (movie_id);
+CREATE INDEX IF NOT EXISTS movie_credits_person_id_idx ON movie_credits (person_id);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    rating smallint NOT NULL,
+    body text NOT NULL DEFAULT '',
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT reviews_rating_check CHECK (rating BETWEEN 1 AND 10),
+    CONSTRAINT reviews_movie_id_user_id_key UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+-- Rating aggregates are kept up to date incrementally every time a review is
+-- created, updated or deleted, the average is derived from them
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_sum bigint NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS average_rating double precision
+GENERATED ALWAYS AS (
+    CASE WHEN rating_count > 0 THEN rating_sum::double precision / rating_count ELSE 0 END
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_average_rating_idx ON movies (average_rating);
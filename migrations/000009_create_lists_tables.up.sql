@@ -0,0 +1,28 @@
+-- The watchlist of a user is stored as a special list, so that it shares the
+-- ordering and notes of the custom lists
+CREATE TABLE IF NOT EXISTS lists (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    is_public bool NOT NULL DEFAULT false,
+    is_watchlist bool NOT NULL DEFAULT false,
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);
+-- A user has at most one watchlist
+CREATE UNIQUE INDEX IF NOT EXISTS lists_user_id_watchlist_idx ON lists (
+    user_id
+) WHERE is_watchlist;
+
+CREATE TABLE IF NOT EXISTS list_items (
+    list_id bigint NOT NULL REFERENCES lists ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer NOT NULL,
+    note text NOT NULL DEFAULT '',
+    added_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (list_id, movie_id),
+    CONSTRAINT list_items_position_check CHECK (position >= 1)
+);
@@ -0,0 +1,15 @@
+-- Nearest neighbors of every movie, precomputed by a background job from the
+-- genres (Jaccard similarity) and the co-ratings of the users (adjusted cosine)
+CREATE TABLE IF NOT EXISTS movie_similarities (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    similar_movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    genre_score double precision NOT NULL DEFAULT 0,
+    rating_score double precision NOT NULL DEFAULT 0,
+    score double precision NOT NULL,
+    computed_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, similar_movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_similarities_movie_id_score_idx ON movie_similarities (
+    movie_id, score DESC
+);
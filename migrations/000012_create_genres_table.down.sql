@@ -0,0 +1,3 @@
+-- The movies genres are left normalized, the original free-form values can't be restored
+DELETE FROM permissions WHERE code = 'genres:write';
+DROP TABLE IF EXISTS genres;
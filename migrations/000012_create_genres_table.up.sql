@@ -0,0 +1,76 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    slug text UNIQUE NOT NULL,
+    name text NOT NULL,
+    aliases text [] NOT NULL DEFAULT '{}',
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING gin (aliases);
+
+INSERT INTO genres (slug, name, aliases)
+VALUES
+('action', 'Action', '{}'),
+('adventure', 'Adventure', '{}'),
+('animation', 'Animation', '{animated,cartoon}'),
+('comedy', 'Comedy', '{}'),
+('crime', 'Crime', '{}'),
+('documentary', 'Documentary', '{doc,docs}'),
+('drama', 'Drama', '{}'),
+('family', 'Family', '{}'),
+('fantasy', 'Fantasy', '{}'),
+('history', 'History', '{historical}'),
+('horror', 'Horror', '{}'),
+('music', 'Music', '{musical}'),
+('mystery', 'Mystery', '{}'),
+('romance', 'Romance', '{romantic}'),
+('science-fiction', 'Science Fiction', '{sci-fi,scifi,sf}'),
+('thriller', 'Thriller', '{}'),
+('war', 'War', '{}'),
+('western', 'Western', '{}')
+ON CONFLICT (slug) DO NOTHING;
+
+-- Keep the genres already used by movies which don't match a seeded genre.
+-- The slug expression must stay in sync with data.GenreSlug
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (used.slug)
+    used.slug,
+    initcap(trim(used.genre))
+FROM (
+    SELECT
+        genre,
+        trim(BOTH '-' FROM regexp_replace(lower(genre), '[^a-z0-9]+', '-', 'g')) AS slug
+    FROM movies, unnest(movies.genres) AS genre
+) AS used
+WHERE used.slug <> '' AND NOT EXISTS (
+    SELECT 1 FROM genres
+    WHERE genres.slug = used.slug OR used.slug = ANY(genres.aliases)
+)
+ORDER BY used.slug, used.genre
+ON CONFLICT (slug) DO NOTHING;
+
+-- Rewrite the genres of every movie to the slugs of the managed genres, the
+-- duplicates created by the normalization are dropped and the order is kept
+UPDATE movies SET genres = normalized.genres
+FROM (
+    SELECT
+        movies.id,
+        ARRAY(
+            SELECT genres.slug
+            FROM unnest(movies.genres) WITH ORDINALITY AS g (name, position)
+            INNER JOIN genres ON
+                genres.slug = trim(BOTH '-' FROM regexp_replace(lower(g.name), '[^a-z0-9]+', '-', 'g'))
+                OR trim(BOTH '-' FROM regexp_replace(lower(g.name), '[^a-z0-9]+', '-', 'g')) = ANY(genres.aliases)
+            GROUP BY genres.slug
+            ORDER BY min(g.position)
+        ) AS genres
+    FROM movies
+) AS normalized
+WHERE
+    movies.id = normalized.id
+    AND movies.genres <> normalized.genres
+    AND cardinality(normalized.genres) > 0;
+
+INSERT INTO permissions (code)
+VALUES ('genres:write');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_release_dates;
+DROP TABLE IF EXISTS movie_titles;
@@ -0,0 +1,19 @@
+-- Alternate titles by BCP 47 locale (e.g. fr, pt-BR)
+CREATE TABLE IF NOT EXISTS movie_titles (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    locale text NOT NULL,
+    title text NOT NULL,
+    PRIMARY KEY (movie_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS movie_titles_title_idx ON movie_titles USING gin (
+    to_tsvector('simple', title)
+);
+
+-- Release dates by ISO 3166-1 alpha-2 country code (e.g. US, FR)
+CREATE TABLE IF NOT EXISTS movie_release_dates (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    release_date date NOT NULL,
+    PRIMARY KEY (movie_id, country)
+);
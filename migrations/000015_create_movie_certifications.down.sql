@@ -0,0 +1,4 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_max_certification_check;
+ALTER TABLE users DROP COLUMN IF EXISTS max_certification;
+ALTER TABLE users DROP COLUMN IF EXISTS max_certification_country;
+DROP TABLE IF EXISTS movie_certifications;
@@ -0,0 +1,16 @@
+-- Certification of a movie by country (e.g. PG-13 for the MPAA in US, 12A for the BBFC in GB). min_age is
+-- derived from the certification so that movies can be compared across countries.
+CREATE TABLE IF NOT EXISTS movie_certifications (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    certification text NOT NULL,
+    min_age smallint NOT NULL CHECK (min_age >= 0),
+    PRIMARY KEY (movie_id, country)
+);
+
+-- Maximum certification a user wants to see, both NULL when there is no limit
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_certification_country text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_certification text;
+ALTER TABLE users ADD CONSTRAINT users_max_certification_check CHECK (
+    (max_certification_country IS NULL) = (max_certification IS NULL)
+);
@@ -0,0 +1,9 @@
+-- Identifiers of the movies in external databases such as IMDb (tt0111161) or TMDb (278). An external ID
+-- belongs to a single movie and a movie has at most one ID per source.
+CREATE TABLE IF NOT EXISTS external_ids (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source text NOT NULL,
+    external_id text NOT NULL,
+    PRIMARY KEY (source, external_id),
+    CONSTRAINT external_ids_movie_source_key UNIQUE (movie_id, source)
+);
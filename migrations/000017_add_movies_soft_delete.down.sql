@@ -0,0 +1,5 @@
+-- The merged movies are removed for good, their data has been moved to the movie they were merged into
+DELETE FROM movies WHERE deleted_at IS NOT NULL;
+DELETE FROM permissions WHERE code = 'movies:admin';
+ALTER TABLE movies DROP COLUMN IF EXISTS merged_into;
+ALTER TABLE movies DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,7 @@
+-- Movies merged into another one are soft deleted so that their history can still be traced,
+-- every query on movies must filter out the rows with a deleted_at
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS merged_into bigint REFERENCES movies ON DELETE SET NULL;
+
+INSERT INTO permissions (code)
+VALUES ('movies:admin');
@@ -0,0 +1,30 @@
+-- Franchises and other groups of movies, such as "The Lord of the Rings"
+CREATE TABLE IF NOT EXISTS collections (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    -- Artwork stored the same way as the movie images, all NULL when there is none
+    artwork_content_type text,
+    artwork_width integer,
+    artwork_height integer,
+    artwork_size bigint,
+    artwork_key text UNIQUE,
+    artwork_thumbnail_keys jsonb,
+    created_at timestamp (0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS collections_name_idx ON collections USING gin (
+    to_tsvector('simple', name)
+);
+
+-- Members of a collection are ordered by their position starting at 1
+CREATE TABLE IF NOT EXISTS collection_movies (
+    collection_id bigint NOT NULL REFERENCES collections ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer NOT NULL,
+    PRIMARY KEY (collection_id, movie_id),
+    CONSTRAINT collection_movies_position_check CHECK (position >= 1)
+);
+
+CREATE INDEX IF NOT EXISTS collection_movies_movie_id_idx ON collection_movies (movie_id);
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the database so that the API binary can apply them,
+// see the migrate command of cmd/api.
+//
+// Every migration is a pair of NNNNNN_name.up.sql and NNNNNN_name.down.sql files.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS